@@ -27,7 +27,7 @@ type clientWrapper struct {
 	client.Client
 }
 
-func (c *clientWrapper) Call(ctx context.Context, req micro.Request, opts ...client.CallOption) (*transport.Message, error) {
+func (c *clientWrapper) breaker(req micro.Request) *gobreaker.TwoStepCircuitBreaker[bool] {
 	var svc string
 
 	switch c.scope {
@@ -38,22 +38,25 @@ func (c *clientWrapper) Call(ctx context.Context, req micro.Request, opts ...cli
 	}
 
 	c.mu.Lock()
+	defer c.mu.Unlock()
 	cb, ok := c.cbs[svc]
 	if !ok {
-		cb = gobreaker.NewTwoStepCircuitBreaker[bool](c.bs)
+		cb = gobreaker.NewTwoStepCircuitBreaker[bool](settings(c.bs, svc))
 		c.cbs[svc] = cb
 	}
-	c.mu.Unlock()
+	return cb
+}
 
-	cbAllow, err := cb.Allow()
+// execute 熔断器内执行请求, 仅5xx错误计为失败
+func (c *clientWrapper) execute(req micro.Request, fn func() error) error {
+	cbAllow, err := c.breaker(req).Allow()
 	if err != nil {
-		return nil, exc.New(req.Service(), err.Error(), 502)
+		return exc.New(req.Service(), err.Error(), 502)
 	}
 
-	var msg *transport.Message
-	if msg, err = c.Client.Call(ctx, req, opts...); err == nil {
+	if err = fn(); err == nil {
 		cbAllow(true)
-		return msg, nil
+		return nil
 	}
 
 	ex := exc.Parse(err.Error())
@@ -64,13 +67,85 @@ func (c *clientWrapper) Call(ctx context.Context, req micro.Request, opts ...cli
 		ex.Id = req.Service()
 	}
 
-	if ex.Code >= 500 {
-		cbAllow(false)
-	} else {
-		cbAllow(true)
+	cbAllow(ex.Code < 500)
+
+	return ex
+}
+
+func (c *clientWrapper) Call(ctx context.Context, req micro.Request, opts ...client.CallOption) (*transport.Message, error) {
+	var msg *transport.Message
+	err := c.execute(req, func() (err error) {
+		msg, err = c.Client.Call(ctx, req, opts...)
+		return
+	})
+	if err != nil {
+		return nil, err
 	}
+	return msg, nil
+}
+
+func (c *clientWrapper) RPC(ctx context.Context, req micro.Request, res *micro.Response, opts ...client.CallOption) error {
+	return c.execute(req, func() error {
+		return c.Client.RPC(ctx, req, res, opts...)
+	})
+}
 
-	return nil, ex
+func (c *clientWrapper) Stream(ctx context.Context, req micro.Request, opts ...client.CallOption) (micro.Stream, error) {
+	var stream micro.Stream
+	err := c.execute(req, func() (err error) {
+		stream, err = c.Client.Stream(ctx, req, opts...)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+func (c *clientWrapper) Publish(ctx context.Context, req micro.Request, opts ...client.CallOption) error {
+	return c.execute(req, func() error {
+		return c.Client.Publish(ctx, req, opts...)
+	})
+}
+
+// Batch 每个子请求独立熔断, 熔断中的子请求不发送
+func (c *clientWrapper) Batch(ctx context.Context, reqs []micro.Request, opts ...client.CallOption) ([]*client.BatchResult, error) {
+	results := make([]*client.BatchResult, len(reqs))
+	var pending []micro.Request
+	var indexes []int
+	var dones []func(bool)
+	for i, req := range reqs {
+		done, err := c.breaker(req).Allow()
+		if err != nil {
+			results[i] = &client.BatchResult{Err: exc.New(req.Service(), err.Error(), 502)}
+			continue
+		}
+		pending = append(pending, req)
+		indexes = append(indexes, i)
+		dones = append(dones, done)
+	}
+	if len(pending) == 0 {
+		return results, nil
+	}
+
+	rs, err := c.Client.Batch(ctx, pending, opts...)
+	for i, index := range indexes {
+		e := err
+		if e == nil {
+			results[index] = rs[i]
+			e = rs[i].Err
+		}
+		if e == nil {
+			dones[i](true)
+			continue
+		}
+		ex := exc.Parse(e.Error())
+		dones[i](ex.Code > 0 && ex.Code < 500)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
 }
 
 // NewClientWrapper returns a client Wrapper.
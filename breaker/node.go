@@ -0,0 +1,172 @@
+package breaker
+
+import (
+	"github.com/lolizeppelin/micro"
+	exc "github.com/lolizeppelin/micro/errors"
+	"github.com/lolizeppelin/micro/selector"
+	"github.com/lolizeppelin/micro/utils"
+	"github.com/sony/gobreaker/v2"
+	"sync"
+)
+
+/*
+nodeSelector 节点级熔断
+1. 选择节点时跳过熔断器处于open状态的节点, 全部打开时返回无可用节点
+2. Next返回节点前向熔断器申请放行(half-open状态下受MaxRequests限制), 未放行时尝试下一节点
+3. 每次放行返回独立的节点副本, Selector.Mark需传入Next返回的节点, 结果回填到该次放行(连接错误与5xx计为失败)
+4. 注册中心中已不存在的节点, 在下一次选择时清理其熔断器
+*/
+type nodeSelector struct {
+	selector.Selector
+	bs      gobreaker.Settings
+	cbs     map[string]map[string]*gobreaker.TwoStepCircuitBreaker[bool] // service -> node id -> 熔断器
+	pending map[*micro.Node]func(bool)                                   // 已放行, 等待Mark回填结果
+	mu      sync.Mutex
+}
+
+func (s *nodeSelector) breaker(service string, node *micro.Node) *gobreaker.TwoStepCircuitBreaker[bool] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	nodes, ok := s.cbs[service]
+	if !ok {
+		nodes = make(map[string]*gobreaker.TwoStepCircuitBreaker[bool])
+		s.cbs[service] = nodes
+	}
+	cb, ok := nodes[node.Id]
+	if !ok {
+		cb = gobreaker.NewTwoStepCircuitBreaker[bool](settings(s.bs, service+"."+node.Id))
+		nodes[node.Id] = cb
+	}
+	return cb
+}
+
+// prune 清理已下线节点的熔断器, 需在其他过滤器之前执行, 传入的是服务全部节点
+func (s *nodeSelector) prune(service string, services []*micro.Service) {
+	ids := make(map[string]bool)
+	for _, srv := range services {
+		for _, node := range srv.Nodes {
+			ids[node.Id] = true
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id := range s.cbs[service] {
+		if !ids[id] {
+			delete(s.cbs[service], id)
+		}
+	}
+	if len(s.cbs[service]) == 0 {
+		delete(s.cbs, service)
+	}
+}
+
+func (s *nodeSelector) filter(services []*micro.Service) ([]*micro.Service, error) {
+	var matched []*micro.Service
+	var total int
+	for _, srv := range services {
+		service := &micro.Service{
+			Name:      srv.Name,
+			Version:   srv.Version,
+			Metadata:  srv.Metadata,
+			Endpoints: srv.Endpoints,
+		}
+		for _, node := range srv.Nodes {
+			total++
+			if s.breaker(srv.Name, node).State() == gobreaker.StateOpen {
+				continue
+			}
+			service.Nodes = append(service.Nodes, node)
+		}
+		if len(service.Nodes) > 0 {
+			matched = append(matched, service)
+		}
+	}
+	if total > 0 && len(matched) == 0 {
+		return nil, micro.ErrNoneServiceAvailable
+	}
+	return matched, nil
+}
+
+// allow 申请放行, 放行时返回节点副本, 放行结果在Mark该副本时回填
+func (s *nodeSelector) allow(service string, node *micro.Node) *micro.Node {
+	done, err := s.breaker(service, node).Allow()
+	if err != nil {
+		return nil
+	}
+	n := *node
+	s.mu.Lock()
+	s.pending[&n] = done
+	s.mu.Unlock()
+	return &n
+}
+
+func (s *nodeSelector) Select(service string, filters ...selector.Filter) (selector.Next, error) {
+	var candidates int
+	filters = utils.MergeSlice(
+		[]selector.Filter{func(services []*micro.Service) ([]*micro.Service, error) {
+			s.prune(service, services)
+			return services, nil
+		}},
+		filters,
+		[]selector.Filter{func(services []*micro.Service) ([]*micro.Service, error) {
+			matched, err := s.filter(services)
+			for _, srv := range matched {
+				candidates += len(srv.Nodes)
+			}
+			return matched, err
+		}},
+	)
+	next, err := s.Selector.Select(service, filters...)
+	if err != nil {
+		return nil, err
+	}
+	return func() (*micro.Node, error) {
+		for i := 0; i < max(candidates, 1); i++ {
+			node, err := next()
+			if err != nil {
+				return nil, err
+			}
+			if n := s.allow(service, node); n != nil {
+				return n, nil
+			}
+		}
+		return nil, micro.ErrNoneServiceAvailable
+	}, nil
+}
+
+func (s *nodeSelector) Mark(service string, node *micro.Node, err error) {
+	s.Selector.Mark(service, node, err)
+	if node == nil {
+		return
+	}
+	s.mu.Lock()
+	done, ok := s.pending[node]
+	delete(s.pending, node)
+	s.mu.Unlock()
+	if !ok { // 未经Select放行的调用结果
+		var e error
+		if done, e = s.breaker(service, node).Allow(); e != nil {
+			return
+		}
+	}
+	if err == nil {
+		done(true)
+		return
+	}
+	ex := exc.FromError(err)
+	done(ex.Code > 0 && ex.Code < 500)
+}
+
+func (s *nodeSelector) Name() string {
+	return s.Selector.Name() + "+breaker"
+}
+
+// NewNodeSelector 包装Selector实现节点级熔断, 通过client.Selector使用
+func NewNodeSelector(s selector.Selector, bs gobreaker.Settings) selector.Selector {
+	return &nodeSelector{
+		Selector: s,
+		bs:       bs,
+		cbs:      make(map[string]map[string]*gobreaker.TwoStepCircuitBreaker[bool]),
+		pending:  make(map[*micro.Node]func(bool)),
+	}
+}
@@ -0,0 +1,109 @@
+package breaker
+
+import (
+	"fmt"
+	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/selector"
+	"github.com/sony/gobreaker/v2"
+	"testing"
+	"time"
+)
+
+type staticSelector struct {
+	services []*micro.Service
+}
+
+func (s *staticSelector) Select(_ string, filters ...selector.Filter) (selector.Next, error) {
+	services := s.services
+	var err error
+	for _, filter := range filters {
+		if services, err = filter(services); err != nil {
+			return nil, err
+		}
+	}
+	return selector.RoundRobin(services), nil
+}
+
+func (s *staticSelector) Mark(string, *micro.Node, error) {}
+func (s *staticSelector) Reset(string)                    {}
+func (s *staticSelector) Close() error                    { return nil }
+func (s *staticSelector) Name() string                    { return "static" }
+
+func TestNodeSelector(t *testing.T) {
+	nodes := []*micro.Node{{Id: "001"}, {Id: "002"}}
+	s := NewNodeSelector(&staticSelector{services: []*micro.Service{{Name: "lobby", Nodes: nodes}}},
+		gobreaker.Settings{ReadyToTrip: func(counts gobreaker.Counts) bool { return counts.ConsecutiveFailures >= 2 }})
+
+	for i := 0; i < 2; i++ {
+		s.Mark("lobby", nodes[0], fmt.Errorf("connection refused"))
+	}
+	next, err := s.Select("lobby")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if node, _ := next(); node.Id != "002" {
+			t.Fatalf("open node %s selected", node.Id)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		s.Mark("lobby", nodes[1], fmt.Errorf("connection refused"))
+	}
+	if _, err = s.Select("lobby"); err != micro.ErrNoneServiceAvailable {
+		t.Fatalf("expect none available, got %v", err)
+	}
+}
+
+func TestNodeSelectorHalfOpen(t *testing.T) {
+	nodes := []*micro.Node{{Id: "001"}, {Id: "002"}}
+	static := &staticSelector{services: []*micro.Service{{Name: "lobby", Nodes: nodes}}}
+	s := NewNodeSelector(static, gobreaker.Settings{
+		MaxRequests: 1,
+		Timeout:     10 * time.Millisecond,
+		ReadyToTrip: func(counts gobreaker.Counts) bool { return counts.ConsecutiveFailures >= 1 },
+	})
+
+	s.Mark("lobby", nodes[0], fmt.Errorf("connection refused"))
+	time.Sleep(20 * time.Millisecond)
+
+	// half-open只放行一个请求
+	next, err := s.Select("lobby")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var probes int
+	var selected []*micro.Node
+	for i := 0; i < 4; i++ {
+		node, err := next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if node.Id == "001" {
+			probes++
+		}
+		selected = append(selected, node)
+	}
+	if probes != 1 {
+		t.Fatalf("half-open node selected %d times", probes)
+	}
+	// 每次放行独立回填, 与Mark顺序无关
+	for i := len(selected) - 1; i >= 0; i-- {
+		s.Mark("lobby", selected[i], nil)
+	}
+	if n := len(s.(*nodeSelector).pending); n != 0 {
+		t.Fatalf("%d pending results not released", n)
+	}
+	if state := s.(*nodeSelector).breaker("lobby", nodes[0]).State(); state != gobreaker.StateClosed {
+		t.Fatalf("breaker not closed after probe succeed: %s", state)
+	}
+
+	// 节点下线后清理熔断器
+	static.services = []*micro.Service{{Name: "lobby", Nodes: nodes[1:]}}
+	if _, err = s.Select("lobby"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.(*nodeSelector).cbs["lobby"]["001"]; ok {
+		t.Fatal("breaker of removed node not pruned")
+	}
+}
@@ -0,0 +1,51 @@
+package breaker
+
+import (
+	"context"
+	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/log"
+	"github.com/lolizeppelin/micro/tracing"
+	"github.com/sony/gobreaker/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const (
+	BreakerScope = "micro/breaker"
+)
+
+var (
+	_version, _ = micro.NewVersion("1.0.0")
+
+	meter       = tracing.GetMeter(BreakerScope, _version)
+	changes, _  = meter.Int64Counter("breaker.state.changes", metric.WithDescription("circuit breaker state changes"))
+	openings, _ = meter.Int64UpDownCounter("breaker.open", metric.WithDescription("circuit breakers in open state"))
+)
+
+// settings 复制熔断配置并挂载状态变更日志与指标
+func settings(bs gobreaker.Settings, name string) gobreaker.Settings {
+	bs.Name = name
+	onStateChange := bs.OnStateChange
+	bs.OnStateChange = func(name string, from gobreaker.State, to gobreaker.State) {
+		ctx := context.Background()
+		if to == gobreaker.StateOpen {
+			log.Warnf(ctx, "circuit breaker %s state changed from %s to %s", name, from, to)
+		} else {
+			log.Infof(ctx, "circuit breaker %s state changed from %s to %s", name, from, to)
+		}
+		changes.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("name", name),
+			attribute.String("from", from.String()),
+			attribute.String("to", to.String()),
+		))
+		if to == gobreaker.StateOpen {
+			openings.Add(ctx, 1, metric.WithAttributes(attribute.String("name", name)))
+		} else if from == gobreaker.StateOpen {
+			openings.Add(ctx, -1, metric.WithAttributes(attribute.String("name", name)))
+		}
+		if onStateChange != nil {
+			onStateChange(name, from, to)
+		}
+	}
+	return bs
+}
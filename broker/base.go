@@ -4,6 +4,8 @@ import (
 	"context"
 	"github.com/lolizeppelin/micro"
 	"github.com/lolizeppelin/micro/transport"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"sync"
 )
 
 const (
@@ -42,16 +44,36 @@ type Subscriber interface {
 	Unsubscribe() error
 }
 
+type keyCtx struct{}
+
+// ContextWithKey 设置消息key, 相同key的消息按发布顺序投递
+func ContextWithKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, keyCtx{}, key)
+}
+
+func KeyFromContext(ctx context.Context) string {
+	key, _ := ctx.Value(keyCtx{}).(string)
+	return key
+}
+
 /* ------------- event -------------*/
 
 type kafkaEvent struct {
-	msg *transport.Message
+	msg       *transport.Message
+	record    *kgo.Record
+	partition *partition
+	offsets   *offsets
+	once      sync.Once
 }
 
 func (s *kafkaEvent) Message() *transport.Message {
 	return s.msg
 }
 
+// Ack 确认消息, 该分区之前的消息全部确认后提交offset
 func (s *kafkaEvent) Ack() error {
+	s.once.Do(func() {
+		s.offsets.ack(s.partition, s.record)
+	})
 	return nil
 }
@@ -0,0 +1,130 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/lolizeppelin/micro/log"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// ReplayIdle 重放死信时分区分配完成后超过该时长无新消息视为已处理完
+	ReplayIdle = 3 * time.Second
+	// ReplayAssignTimeout 重放死信时等待消费组分配分区的最长时间
+	ReplayAssignTimeout = 30 * time.Second
+)
+
+func recordHeaders(headers map[string]string) []kgo.RecordHeader {
+	hdr := make([]kgo.RecordHeader, 0, len(headers))
+	for k, v := range headers {
+		hdr = append(hdr, kgo.RecordHeader{Key: k, Value: []byte(v)})
+	}
+	return hdr
+}
+
+// deadLetter 同步发布到死信topic, 消费者客户端默认等待全部副本确认
+func (s *KafkaSubscriber) deadLetter(ctx context.Context, record *kgo.Record, attempts int, cause error) error {
+	headers := deadLetterHeaders(kafkaRecord(record).Headers, record.Topic, attempts, cause)
+	headers[HeaderDeadLetterPartition] = strconv.Itoa(int(record.Partition))
+	headers[HeaderDeadLetterOffset] = strconv.FormatInt(record.Offset, 10)
+	return s.client.ProduceSync(ctx, &kgo.Record{
+		Topic:   s.retry.deadLetter(record.Topic),
+		Key:     record.Key,
+		Value:   record.Value,
+		Headers: recordHeaders(headers),
+	}).FirstErr()
+}
+
+/*
+Replay 将死信消息重新发布到原topic
+1. 使用消费组 {死信topic}.replay 读取, 重新发布成功后提交offset, 已重放的消息不会重复重放
+2. 等待消费组分配分区后开始计时, 连续ReplayIdle无新消息或达到limit后返回
+3. 超过ReplayAssignTimeout未分配或分配结果为空(e.g 其他重放正在进行)时返回错误
+4. 缺少原topic头的消息跳过
+*/
+func (k *KafkaBroker) Replay(ctx context.Context, deadLetter string, limit int) (int, error) {
+	assigned := make(chan int, 1)
+	var once sync.Once
+	client, err := kgo.NewClient(
+		kgo.SeedBrokers(k.opts.Address...),
+		kgo.ConsumerGroup(deadLetter+".replay"),
+		kgo.ConsumeTopics(deadLetter),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+		kgo.DisableAutoCommit(),
+		kgo.OnPartitionsAssigned(func(_ context.Context, _ *kgo.Client, partitions map[string][]int32) {
+			once.Do(func() { assigned <- len(partitions[deadLetter]) })
+		}),
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+
+	// 首次加入消费组可能超过ReplayIdle, 分配完成前不计时
+	timer := time.NewTimer(ReplayAssignTimeout)
+	defer timer.Stop()
+	select {
+	case n := <-assigned:
+		if n == 0 {
+			return 0, fmt.Errorf("dead letter %s replay no partition assigned", deadLetter)
+		}
+	case <-timer.C:
+		return 0, fmt.Errorf("dead letter %s replay partition assignment timeout", deadLetter)
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+
+	var replayed int
+	for limit <= 0 || replayed < limit {
+		_ctx, cancel := context.WithTimeout(ctx, ReplayIdle)
+		fetches := client.PollRecords(_ctx, 100)
+		cancel()
+		if err = ctx.Err(); err != nil {
+			return replayed, err
+		}
+		if err = fetches.Err(); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			return replayed, err
+		}
+		records := fetches.Records()
+		if len(records) == 0 {
+			break
+		}
+		var done []*kgo.Record
+		for _, record := range records {
+			if limit > 0 && replayed >= limit {
+				break
+			}
+			headers := kafkaRecord(record).Headers
+			topic := headers[HeaderDeadLetterTopic]
+			if topic == "" {
+				log.Warnf(ctx, "dead letter %s offset %d without origin topic, skip", deadLetter, record.Offset)
+				done = append(done, record)
+				continue
+			}
+			err = client.ProduceSync(ctx, &kgo.Record{
+				Topic:   topic,
+				Key:     record.Key,
+				Value:   record.Value,
+				Headers: recordHeaders(originHeaders(headers)),
+			}).FirstErr()
+			if err != nil {
+				break
+			}
+			done = append(done, record)
+			replayed++
+		}
+		if len(done) > 0 {
+			if e := client.CommitRecords(ctx, done...); e != nil && err == nil {
+				err = e
+			}
+		}
+		if err != nil {
+			return replayed, err
+		}
+	}
+	return replayed, nil
+}
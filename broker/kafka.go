@@ -6,16 +6,15 @@ import (
 	"github.com/twmb/franz-go/pkg/kgo"
 )
 
-func NewKafkaConsumer(ctx context.Context, address []string, topics string, opts SubscribeOptions) (*kgo.Client, error) {
+// NewKafkaConsumer extra为附加配置, e.g 位移提交与分区回收回调
+func NewKafkaConsumer(ctx context.Context, address []string, topics string, opts SubscribeOptions, extra ...kgo.Opt) (*kgo.Client, error) {
 	options := []kgo.Opt{
 		kgo.SeedBrokers(address...),
 		kgo.ConsumerGroup(opts.Queue),
 		kgo.ConsumeTopics(topics),
 		kgo.DisableIdempotentWrite(),
 	}
-	if opts.AutoAck {
-		options = append(options, kgo.RequiredAcks(kgo.NoAck()))
-	}
+	options = append(options, extra...)
 	client, err := kgo.NewClient(options...)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,379 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"github.com/lolizeppelin/micro/tracing"
+	"github.com/lolizeppelin/micro/transport"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"sync"
+	"time"
+)
+
+/*
+MemoryBroker 进程内broker, 用于测试与无kafka的单进程部署
+1. 相同Queue的订阅者组成消费组, 每条消息只投递给组内一个订阅者; 无Queue的订阅者独立接收全部消息
+2. 消息确认(AutoAck时handler返回nil即确认)前不投递同key的后续消息, 保证同key有序
+3. handler返回错误或未确认的消息在RedeliverDelay后重新投递
+4. 消费组无订阅者时消息保留, 有订阅者加入后继续投递
+5. 设置重试策略时handler失败按策略延迟重投, 超过次数进入死信topic并保留, 可通过Replay重新投递
+*/
+type MemoryBroker struct {
+	opts *Options
+
+	mu          sync.Mutex
+	connected   bool
+	topics      map[string]map[string]*memoryGroup // topic -> group
+	deadLetters map[string][]*delivery             // 死信topic -> 待重放消息
+}
+
+func NewMemoryBroker(opts ...Option) *MemoryBroker {
+	return &MemoryBroker{
+		opts:        NewOptions(opts...),
+		topics:      make(map[string]map[string]*memoryGroup),
+		deadLetters: make(map[string][]*delivery),
+	}
+}
+
+func (m *MemoryBroker) Name() string {
+	return "memory"
+}
+
+func (m *MemoryBroker) Connect() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.connected {
+		return fmt.Errorf("broker connected")
+	}
+	m.connected = true
+	return nil
+}
+
+func (m *MemoryBroker) Disconnect() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.connected = false
+	for _, groups := range m.topics {
+		for _, group := range groups {
+			group.close()
+		}
+	}
+	m.topics = make(map[string]map[string]*memoryGroup)
+	return nil
+}
+
+func (m *MemoryBroker) Publish(ctx context.Context, topic string, msg *transport.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.connected {
+		return fmt.Errorf("broker not connect")
+	}
+	d := &delivery{
+		key:     KeyFromContext(ctx),
+		headers: tracing.Inject(ctx),
+		msg:     msg,
+	}
+	for _, group := range m.topics[topic] {
+		group.push(d)
+	}
+	return nil
+}
+
+func (m *MemoryBroker) Subscribe(_ context.Context, topic string, handler Handler, opts ...SubscribeOption) (Subscriber, error) {
+	options := NewSubscribeOptions(opts...)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.connected {
+		return nil, fmt.Errorf("broker not connect")
+	}
+
+	s := &memorySubscriber{
+		broker:  m,
+		topic:   topic,
+		handler: handler,
+		autoAck: options.AutoAck,
+		retry:   options.Retry,
+		tracer:  tracing.GetTracer(HandlerScope, _version),
+	}
+	name := options.Queue
+	if name == "" { // 独立订阅
+		name = fmt.Sprintf("%p", s)
+	}
+	groups, ok := m.topics[topic]
+	if !ok {
+		groups = make(map[string]*memoryGroup)
+		m.topics[topic] = groups
+	}
+	group, ok := groups[name]
+	if !ok {
+		group = newMemoryGroup(topic, options.Queue == "", m.opts)
+		groups[name] = group
+	}
+	s.group = group
+	s.name = name
+	group.join(s)
+	return s, nil
+}
+
+func (m *MemoryBroker) unsubscribe(s *memorySubscriber) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s.group.leave(s) {
+		s.group.close()
+		delete(m.topics[s.topic], s.name)
+	}
+}
+
+type delivery struct {
+	key      string
+	headers  map[string]string
+	msg      *transport.Message
+	attempts int // 处理失败次数
+}
+
+type memoryGroup struct {
+	topic     string
+	exclusive bool // 独立订阅, 订阅者退出时删除
+	opts      *Options
+
+	mu       sync.Mutex
+	pending  []*delivery
+	inflight map[string]bool
+	members  []*memorySubscriber
+	next     int
+	closed   bool
+}
+
+func newMemoryGroup(topic string, exclusive bool, opts *Options) *memoryGroup {
+	return &memoryGroup{
+		topic:     topic,
+		exclusive: exclusive,
+		opts:      opts,
+		inflight:  make(map[string]bool),
+	}
+}
+
+func (g *memoryGroup) join(s *memorySubscriber) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.members = append(g.members, s)
+	g.dispatch()
+}
+
+// leave 返回true表示消费组可删除
+func (g *memoryGroup) leave(s *memorySubscriber) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for i, member := range g.members {
+		if member == s {
+			g.members = append(g.members[:i], g.members[i+1:]...)
+			break
+		}
+	}
+	return g.exclusive && len(g.members) == 0
+}
+
+func (g *memoryGroup) close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.closed = true
+	g.pending = nil
+}
+
+func (g *memoryGroup) push(d *delivery) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return
+	}
+	// 每个消费组独立计数
+	cp := *d
+	g.pending = append(g.pending, &cp)
+	g.dispatch()
+}
+
+// dispatch 按顺序投递, 同key消息在前一条确认前不投递, 需持有锁
+func (g *memoryGroup) dispatch() {
+	if len(g.members) == 0 {
+		return
+	}
+	var remain []*delivery
+	blocked := make(map[string]bool)
+	for _, d := range g.pending {
+		if d.key != "" && (g.inflight[d.key] || blocked[d.key]) {
+			blocked[d.key] = true
+			remain = append(remain, d)
+			continue
+		}
+		if d.key != "" {
+			g.inflight[d.key] = true
+		}
+		member := g.members[g.next%len(g.members)]
+		g.next++
+		member.deliver(d)
+	}
+	g.pending = remain
+}
+
+// done 消息处理完成, acked为false时延迟重新投递
+func (g *memoryGroup) done(d *delivery, acked bool) {
+	if acked {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		delete(g.inflight, d.key)
+		g.dispatch()
+		return
+	}
+	g.redeliver(d, g.opts.RedeliverDelay)
+}
+
+// redeliver 延迟后重新投递
+func (g *memoryGroup) redeliver(d *delivery, delay time.Duration) {
+	time.AfterFunc(delay, func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if g.closed {
+			return
+		}
+		delete(g.inflight, d.key)
+		g.pending = append([]*delivery{d}, g.pending...)
+		g.dispatch()
+	})
+}
+
+// deadLetter 发布到死信topic并保留, 用于Replay
+func (m *MemoryBroker) deadLetter(topic, origin string, d *delivery, cause error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dl := &delivery{
+		key:     d.key,
+		headers: deadLetterHeaders(d.headers, origin, d.attempts, cause),
+		msg:     copyMessage(d.msg),
+	}
+	m.deadLetters[topic] = append(m.deadLetters[topic], dl)
+	for _, group := range m.topics[topic] {
+		group.push(dl)
+	}
+}
+
+// Replay 将保留的死信消息重新发布到原topic
+func (m *MemoryBroker) Replay(_ context.Context, deadLetter string, limit int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.connected {
+		return 0, fmt.Errorf("broker not connect")
+	}
+	letters := m.deadLetters[deadLetter]
+	if limit > 0 && limit < len(letters) {
+		letters = letters[:limit]
+	}
+	var replayed int
+	for _, dl := range letters {
+		origin := dl.headers[HeaderDeadLetterTopic]
+		if origin == "" {
+			continue
+		}
+		d := &delivery{
+			key:     dl.key,
+			headers: originHeaders(dl.headers),
+			msg:     dl.msg,
+		}
+		for _, group := range m.topics[origin] {
+			group.push(d)
+		}
+		replayed++
+	}
+	m.deadLetters[deadLetter] = m.deadLetters[deadLetter][len(letters):]
+	return replayed, nil
+}
+
+type memorySubscriber struct {
+	broker  *MemoryBroker
+	group   *memoryGroup
+	name    string
+	topic   string
+	handler Handler
+	autoAck bool
+	retry   *RetryPolicy
+	tracer  oteltrace.Tracer
+	wg      sync.WaitGroup
+}
+
+func (s *memorySubscriber) Topic() string {
+	return s.topic
+}
+
+func (s *memorySubscriber) Unsubscribe() error {
+	s.broker.unsubscribe(s)
+	s.wg.Wait()
+	return nil
+}
+
+func (s *memorySubscriber) deliver(d *delivery) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		event := &memoryEvent{msg: copyMessage(d.msg)}
+
+		ctx := tracing.Extract(context.Background(), d.headers)
+		var span oteltrace.Span
+		ctx, span = s.tracer.Start(ctx, "memory.consume",
+			oteltrace.WithAttributes(
+				attribute.String("endpoint", d.msg.Header[transport.Endpoint]),
+			),
+		)
+		defer span.End()
+
+		err := s.handler(ctx, event)
+		if err != nil {
+			span.RecordError(err)
+			s.broker.opts.ErrorHandler(ctx, "memory.handler", &Record{
+				Topic:   s.topic,
+				Key:     []byte(d.key),
+				Headers: d.headers,
+				Raw:     d.msg,
+			}, err)
+		} else if s.autoAck {
+			_ = event.Ack()
+		}
+		if err != nil && s.retry != nil {
+			d.attempts++
+			if s.retry.retry(d.attempts) {
+				s.group.redeliver(d, s.retry.delay(d.attempts))
+				return
+			}
+			s.broker.deadLetter(s.retry.deadLetter(s.topic), s.topic, d, err)
+			s.group.done(d, true)
+			return
+		}
+		s.group.done(d, err == nil && event.acked)
+	}()
+}
+
+type memoryEvent struct {
+	msg   *transport.Message
+	acked bool
+}
+
+func (e *memoryEvent) Message() *transport.Message {
+	return e.msg
+}
+
+func (e *memoryEvent) Ack() error {
+	e.acked = true
+	return nil
+}
+
+// copyMessage 每个订阅者获得独立副本
+func copyMessage(msg *transport.Message) *transport.Message {
+	cp := &transport.Message{
+		Header: make(map[string]string, len(msg.Header)),
+		Query:  msg.Query,
+		Body:   append([]byte(nil), msg.Body...),
+	}
+	for k, v := range msg.Header {
+		cp.Header[k] = v
+	}
+	return cp
+}
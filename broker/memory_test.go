@@ -0,0 +1,73 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"github.com/lolizeppelin/micro/transport"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryBroker(t *testing.T) {
+	b := NewMemoryBroker(RedeliverDelay(10 * time.Millisecond))
+	_ = b.Connect()
+	defer b.Disconnect()
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	received := map[string][]string{}
+	failed := map[string]bool{}
+	handler := func(name string) Handler {
+		return func(_ context.Context, event Event) error {
+			body := string(event.Message().Body)
+			mu.Lock()
+			defer mu.Unlock()
+			// 首次处理失败, 等待重新投递
+			if body == "a2" && !failed[name] {
+				failed[name] = true
+				return errors.New("failed")
+			}
+			received[name] = append(received[name], body)
+			return nil
+		}
+	}
+
+	s1, _ := b.Subscribe(ctx, "topic", handler("group"), WithQueue("group"))
+	s2, _ := b.Subscribe(ctx, "topic", handler("group"), WithQueue("group"))
+	s3, _ := b.Subscribe(ctx, "topic", handler("single"))
+
+	for _, body := range []string{"a1", "a2", "a3"} {
+		_ = b.Publish(ContextWithKey(ctx, "a"), "topic", &transport.Message{Body: []byte(body)})
+	}
+	_ = b.Publish(ctx, "topic", &transport.Message{Body: []byte("b")})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		done := len(received["group"]) == 4 && len(received["single"]) == 4
+		mu.Unlock()
+		if done {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	_ = s1.Unsubscribe()
+	_ = s2.Unsubscribe()
+	_ = s3.Unsubscribe()
+
+	for name, bodies := range received {
+		if len(bodies) != 4 {
+			t.Fatalf("%s received %v", name, bodies)
+		}
+		var ordered []string
+		for _, body := range bodies {
+			if body != "b" {
+				ordered = append(ordered, body)
+			}
+		}
+		if ordered[0] != "a1" || ordered[1] != "a2" || ordered[2] != "a3" {
+			t.Fatalf("%s key order broken: %v", name, ordered)
+		}
+	}
+}
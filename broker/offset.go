@@ -0,0 +1,127 @@
+package broker
+
+import (
+	"context"
+	"github.com/lolizeppelin/micro/log"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"sync"
+)
+
+/*
+offsets 消费位移管理, 实现至少一次投递
+1. 拉取的消息按分区顺序记录, 确认后仅标记连续已确认的最大offset, 由AutoCommitMarks定时提交
+2. 分区被回收时同步提交已标记的offset并丢弃该分区记录, 回收后的确认不再生效(消息由新消费者重新投递)
+3. 分区丢失时直接丢弃记录, 不提交
+4. 未确认的消息阻塞所在分区offset推进, 之后的消息记录持续增长直到确认或分区回收
+5. 失败消息不确认(设置RetryPolicy时进入死信后确认), 手动确认时handler必须确认每条消息
+*/
+type offsets struct {
+	mu         sync.Mutex
+	client     *kgo.Client
+	partitions map[string]map[int32]*partition
+}
+
+// partition 单个分区已拉取未提交的消息
+type partition struct {
+	records []*kgo.Record // 按offset递增
+	acked   map[int64]bool
+	revoked bool
+}
+
+func newOffsets() *offsets {
+	return &offsets{
+		partitions: make(map[string]map[int32]*partition),
+	}
+}
+
+// options 消费组位移相关配置
+func (o *offsets) options() []kgo.Opt {
+	return []kgo.Opt{
+		kgo.AutoCommitMarks(),
+		kgo.BlockRebalanceOnPoll(),
+		kgo.OnPartitionsAssigned(o.assigned),
+		kgo.OnPartitionsRevoked(o.revoked),
+		kgo.OnPartitionsLost(o.lost),
+	}
+}
+
+// track 记录拉取的消息
+func (o *offsets) track(record *kgo.Record) *partition {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	partitions, ok := o.partitions[record.Topic]
+	if !ok {
+		partitions = make(map[int32]*partition)
+		o.partitions[record.Topic] = partitions
+	}
+	p, ok := partitions[record.Partition]
+	if !ok {
+		p = &partition{acked: make(map[int64]bool)}
+		partitions[record.Partition] = p
+	}
+	p.records = append(p.records, record)
+	return p
+}
+
+// ack 确认消息, 标记连续已确认的最大offset, 在锁内标记避免与分区回收并发
+func (o *offsets) ack(p *partition, record *kgo.Record) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if last := p.advance(record); last != nil {
+		o.client.MarkCommitRecords(last)
+	}
+}
+
+// advance 返回连续已确认的最后一条消息, 无推进或分区已回收返回nil
+func (p *partition) advance(record *kgo.Record) *kgo.Record {
+	if p.revoked {
+		return nil
+	}
+	p.acked[record.Offset] = true
+	var last *kgo.Record
+	for len(p.records) > 0 && p.acked[p.records[0].Offset] {
+		last = p.records[0]
+		delete(p.acked, last.Offset)
+		p.records = p.records[1:]
+	}
+	return last
+}
+
+// drop 丢弃分区记录, 之后的确认不再生效
+func (o *offsets) drop(topics map[string][]int32) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for topic, ids := range topics {
+		partitions := o.partitions[topic]
+		for _, id := range ids {
+			if p, ok := partitions[id]; ok {
+				p.revoked = true
+				delete(partitions, id)
+			}
+		}
+		if len(partitions) == 0 {
+			delete(o.partitions, topic)
+		}
+	}
+}
+
+// commit 同步提交已标记的offset
+func (o *offsets) commit(ctx context.Context) {
+	if err := o.client.CommitMarkedOffsets(ctx); err != nil {
+		log.Errorf(ctx, "kafka commit offsets failed: %s", err.Error())
+	}
+}
+
+func (o *offsets) assigned(_ context.Context, _ *kgo.Client, assigned map[string][]int32) {
+	// 重新分配的分区从已提交位置开始, 丢弃旧记录
+	o.drop(assigned)
+}
+
+func (o *offsets) revoked(ctx context.Context, _ *kgo.Client, revoked map[string][]int32) {
+	o.commit(ctx)
+	o.drop(revoked)
+}
+
+func (o *offsets) lost(_ context.Context, _ *kgo.Client, lost map[string][]int32) {
+	o.drop(lost)
+}
@@ -0,0 +1,80 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"github.com/lolizeppelin/micro/tracing"
+	"github.com/lolizeppelin/micro/transport"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"testing"
+)
+
+func TestOffsets(t *testing.T) {
+	o := newOffsets()
+	records := make([]*kgo.Record, 3)
+	var p *partition
+	for i := range records {
+		records[i] = &kgo.Record{Topic: "lobby", Partition: 0, Offset: int64(i)}
+		p = o.track(records[i])
+	}
+	// 乱序确认, 之前的消息未确认时不推进
+	if last := p.advance(records[1]); last != nil {
+		t.Fatalf("expect no commit before offset 0 acked, got %d", last.Offset)
+	}
+	if last := p.advance(records[0]); last != records[1] {
+		t.Fatalf("expect commit through offset 1, got %v", last)
+	}
+
+	// 分区回收后确认不再生效
+	o.drop(map[string][]int32{"lobby": {0}})
+	if last := p.advance(records[2]); last != nil {
+		t.Fatal("ack after revoke should be ignored")
+	}
+	if q := o.track(&kgo.Record{Topic: "lobby", Partition: 0, Offset: 2}); q == p {
+		t.Fatal("expect new partition state after reassignment")
+	}
+}
+
+func TestAutoAckFailed(t *testing.T) {
+	client, err := kgo.NewClient(kgo.SeedBrokers("127.0.0.1:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	tracker := newOffsets()
+	tracker.client = client
+
+	var handled []error
+	var calls int
+	s := &KafkaSubscriber{
+		tracer:  tracing.GetTracer(HandlerScope, _version),
+		client:  client,
+		offsets: tracker,
+		handler: func(context.Context, Event) error {
+			if calls++; calls == 1 {
+				return errors.New("failed")
+			}
+			return nil
+		},
+		fallback: func(_ context.Context, _ string, _ *Record, err error) {
+			handled = append(handled, err)
+		},
+		unmarshal: func([]byte) (*transport.Message, error) { return &transport.Message{}, nil },
+		autoAck:   true,
+		ctx:       context.Background(),
+	}
+	records := []*kgo.Record{{Topic: "lobby", Offset: 0}, {Topic: "lobby", Offset: 1}}
+	s.fire(kgo.Fetches{{Topics: []kgo.FetchTopic{{Topic: "lobby",
+		Partitions: []kgo.FetchPartition{{Records: records}}}}}})
+
+	// 失败消息交由ErrorHandler但不确认, 之后成功的消息同样不提交, 等待重新投递
+	if len(handled) != 1 {
+		t.Fatalf("expect failure passed to error handler, got %d", len(handled))
+	}
+	if marked := client.MarkedOffsets(); len(marked) != 0 {
+		t.Fatalf("failed message offset committed: %v", marked)
+	}
+	if p := tracker.partitions["lobby"][0]; p == nil || len(p.records) != 2 || !p.acked[1] {
+		t.Fatalf("failed message acked: %+v", p)
+	}
+}
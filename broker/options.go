@@ -5,8 +5,8 @@ import (
 	"crypto/tls"
 	"github.com/lolizeppelin/micro"
 	"github.com/lolizeppelin/micro/transport"
-	"github.com/twmb/franz-go/pkg/kgo"
 	"github.com/vmihailenco/msgpack/v5"
+	"time"
 )
 
 type Options struct {
@@ -22,9 +22,21 @@ type Options struct {
 	TLSConfig *tls.Config
 	Address   []string
 	Secure    bool
+
+	// 未确认消息重新投递间隔(memory)
+	RedeliverDelay time.Duration
+}
+
+// Record is a broker neutral raw record passed to ErrorHandler.
+type Record struct {
+	Topic   string
+	Key     []byte
+	Value   []byte
+	Headers map[string]string
+	Raw     interface{} // 原始broker记录 e.g *kgo.Record
 }
 
-type ErrorHandler func(context.Context, string, *kgo.Record, error)
+type ErrorHandler func(context.Context, string, *Record, error)
 
 type Option func(*Options)
 
@@ -36,13 +48,14 @@ func _unmarshal(b []byte) (*transport.Message, error) {
 	return msg, nil
 }
 
-func _noneHandler(context.Context, string, *kgo.Record, error) {
+func _noneHandler(context.Context, string, *Record, error) {
 	return
 }
 
 func NewOptions(opts ...Option) *Options {
 	options := Options{
-		ErrorHandler: _noneHandler,
+		ErrorHandler:   _noneHandler,
+		RedeliverDelay: 100 * time.Millisecond,
 	}
 	for _, o := range opts {
 		o(&options)
@@ -75,6 +88,13 @@ func WithErrorHandler(fallback ErrorHandler) Option {
 	}
 }
 
+// RedeliverDelay sets the delay before an unacked message is redelivered.
+func RedeliverDelay(d time.Duration) Option {
+	return func(o *Options) {
+		o.RedeliverDelay = d
+	}
+}
+
 // Secure communication with the broker.
 func Secure(b bool) Option {
 	return func(o *Options) {
@@ -99,6 +119,9 @@ type SubscribeOptions struct {
 
 	// 解析
 	Unmarshal SubscribeUnmarshal
+
+	// 处理失败重试策略, 为空时不重试
+	Retry *RetryPolicy
 }
 
 type SubscribeOption func(*SubscribeOptions)
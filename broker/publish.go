@@ -33,15 +33,15 @@ func (k *KafkaBroker) Publish(ctx context.Context, topic string, msg *transport.
 		Value:   buff,
 		Headers: headers,
 	}
-	//if key := ctx.Value(_ctxKey); key != nil {
-	//	record.Key = []byte(key.(string))
-	//}
+	if key := KeyFromContext(ctx); key != "" {
+		record.Key = []byte(key)
+	}
 	//if key := ctx.Value(_ctxPartKey); key != nil {
 	//	record.Partition = key.(int32)
 	//}
 
 	k.producer.TryProduce(ctx, record, func(record *kgo.Record, err error) {
-		k.opts.ErrorHandler(ctx, "push", record, err)
+		k.opts.ErrorHandler(ctx, "push", kafkaRecord(record), err)
 	})
 	return nil
 }
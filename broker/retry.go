@@ -0,0 +1,92 @@
+package broker
+
+import (
+	"context"
+	"github.com/lolizeppelin/micro/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DeadLetterSuffix 默认死信topic后缀
+	DeadLetterSuffix = ".dlq"
+
+	HeaderDeadLetterPrefix    = "x-dead-letter-"
+	HeaderDeadLetterTopic     = HeaderDeadLetterPrefix + "topic"     // 原topic
+	HeaderDeadLetterError     = HeaderDeadLetterPrefix + "error"     // 最后一次处理错误
+	HeaderDeadLetterAttempts  = HeaderDeadLetterPrefix + "attempts"  // 处理次数
+	HeaderDeadLetterTime      = HeaderDeadLetterPrefix + "time"      // 进入死信时间
+	HeaderDeadLetterPartition = HeaderDeadLetterPrefix + "partition" // 原分区(kafka)
+	HeaderDeadLetterOffset    = HeaderDeadLetterPrefix + "offset"    // 原offset(kafka)
+)
+
+/*
+RetryPolicy 订阅处理失败的重试策略
+1. 处理失败后在进程内延迟重试, 重试期间同分区(kafka)/同key(memory)后续消息等待, 保持顺序
+2. 达到最大次数后发布到死信topic, 附带错误信息头并确认原消息
+3. kafka下重试总时长应小于消费组rebalance超时
+*/
+type RetryPolicy struct {
+	Attempts   int                             // 最大处理次数(含首次)
+	Backoff    func(attempt int) time.Duration // 第attempt次失败后的等待时间, 默认utils.BackoffDelay
+	DeadLetter string                          // 死信topic, 默认 原topic + DeadLetterSuffix
+}
+
+// WithRetry sets the retry policy of the subscription.
+func WithRetry(policy RetryPolicy) SubscribeOption {
+	return func(o *SubscribeOptions) {
+		o.Retry = &policy
+	}
+}
+
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	if p.Backoff != nil {
+		return p.Backoff(attempt)
+	}
+	return utils.BackoffDelay(attempt)
+}
+
+// retry 第attempt次失败后是否继续重试
+func (p *RetryPolicy) retry(attempt int) bool {
+	return attempt < p.Attempts
+}
+
+func (p *RetryPolicy) deadLetter(topic string) string {
+	if p.DeadLetter != "" {
+		return p.DeadLetter
+	}
+	return DeadLetterTopic(topic)
+}
+
+// DeadLetterTopic 默认死信topic
+func DeadLetterTopic(topic string) string {
+	return topic + DeadLetterSuffix
+}
+
+// deadLetterHeaders 原消息头去除旧死信头后写入本次死信信息
+func deadLetterHeaders(headers map[string]string, topic string, attempts int, err error) map[string]string {
+	hdr := originHeaders(headers)
+	hdr[HeaderDeadLetterTopic] = topic
+	hdr[HeaderDeadLetterError] = err.Error()
+	hdr[HeaderDeadLetterAttempts] = strconv.Itoa(attempts)
+	hdr[HeaderDeadLetterTime] = time.Now().UTC().Format(time.RFC3339)
+	return hdr
+}
+
+// originHeaders 去除死信信息头
+func originHeaders(headers map[string]string) map[string]string {
+	hdr := make(map[string]string, len(headers)+4)
+	for k, v := range headers {
+		if !strings.HasPrefix(k, HeaderDeadLetterPrefix) {
+			hdr[k] = v
+		}
+	}
+	return hdr
+}
+
+// Replayer 支持将死信消息重新投递到原topic的broker
+type Replayer interface {
+	// Replay 重新投递最多limit条死信消息(limit<=0全部), 返回投递数量
+	Replay(ctx context.Context, deadLetter string, limit int) (int, error)
+}
@@ -0,0 +1,77 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"github.com/lolizeppelin/micro/transport"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDeadLetter(t *testing.T) {
+	b := NewMemoryBroker()
+	_ = b.Connect()
+	defer b.Disconnect()
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var attempts int
+	healthy := false
+	replayed := make(chan string, 1)
+	sub, _ := b.Subscribe(ctx, "topic", func(_ context.Context, event Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if !healthy {
+			attempts++
+			return errors.New("handler failed")
+		}
+		replayed <- string(event.Message().Body)
+		return nil
+	}, WithRetry(RetryPolicy{Attempts: 3, Backoff: func(int) time.Duration { return time.Millisecond }}))
+	defer sub.Unsubscribe()
+
+	letters := make(chan Event, 1)
+	dlq, _ := b.Subscribe(ctx, DeadLetterTopic("topic"), func(_ context.Context, event Event) error {
+		letters <- event
+		return nil
+	})
+	defer dlq.Unsubscribe()
+
+	_ = b.Publish(ctx, "topic", &transport.Message{Body: []byte("a")})
+	select {
+	case <-letters:
+	case <-time.After(time.Second):
+		t.Fatal("expect message in dead letter topic")
+	}
+	mu.Lock()
+	if attempts != 3 {
+		t.Fatalf("expect 3 attempts, got %d", attempts)
+	}
+	healthy = true
+	mu.Unlock()
+
+	b.mu.Lock()
+	headers := b.deadLetters[DeadLetterTopic("topic")][0].headers
+	b.mu.Unlock()
+	if headers[HeaderDeadLetterTopic] != "topic" || headers[HeaderDeadLetterError] != "handler failed" ||
+		headers[HeaderDeadLetterAttempts] != "3" {
+		t.Fatalf("unexpected dead letter headers %v", headers)
+	}
+
+	n, err := b.Replay(ctx, DeadLetterTopic("topic"), 0)
+	if err != nil || n != 1 {
+		t.Fatalf("expect 1 replayed, got %d %v", n, err)
+	}
+	select {
+	case body := <-replayed:
+		if body != "a" {
+			t.Fatalf("unexpected replayed body %s", body)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expect replayed message delivered")
+	}
+	if n, _ = b.Replay(ctx, DeadLetterTopic("topic"), 0); n != 0 {
+		t.Fatalf("dead letter replayed twice")
+	}
+}
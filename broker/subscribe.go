@@ -8,25 +8,41 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 	oteltrace "go.opentelemetry.io/otel/trace"
 	"sync"
+	"time"
 )
 
+/*
+Subscribe 订阅topic, 至少一次投递
+1. AutoAck时handler返回nil即确认, 返回错误的消息不确认, 该分区之后的offset暂停提交, 重启或分区重新分配后重新投递
+2. DisableAutoAck时仅在handler调用Event.Ack()后确认
+3. 同一分区的offset只在之前的消息全部确认后提交
+4. 设置重试策略(WithRetry)时失败消息重试后进入死信topic, 参考 RetryPolicy, 避免失败消息阻塞分区需设置重试策略
+*/
 func (k *KafkaBroker) Subscribe(ctx context.Context, topic string, handler Handler, opts ...SubscribeOption) (Subscriber, error) {
 	options := NewSubscribeOptions(opts...)
-	client, err := NewKafkaConsumer(ctx, k.opts.Address, topic, options)
+	tracker := newOffsets()
+	client, err := NewKafkaConsumer(ctx, k.opts.Address, topic, options, tracker.options()...)
 	if err != nil {
 		return nil, err
 	}
+	tracker.client = client
 
+	_ctx, cancel := context.WithCancel(context.Background())
 	subscriber := &KafkaSubscriber{
 		tracer:    tracing.GetTracer(HandlerScope, _version),
 		topic:     topic,
 		client:    client,
+		offsets:   tracker,
 		handler:   handler,
 		fallback:  k.opts.ErrorHandler,
 		unmarshal: options.Unmarshal,
-		stop:      make(chan struct{}),
+		autoAck:   options.AutoAck,
+		retry:     options.Retry,
+		ctx:       _ctx,
+		cancel:    cancel,
 		wg:        new(sync.WaitGroup),
 	}
+	subscriber.wg.Add(1)
 	go subscriber.start()
 	return subscriber, nil
 }
@@ -35,9 +51,13 @@ type KafkaSubscriber struct {
 	topic     string
 	tracer    oteltrace.Tracer
 	client    *kgo.Client
+	offsets   *offsets
 	handler   Handler
-	stop      chan struct{}
+	ctx       context.Context
+	cancel    context.CancelFunc
 	fallback  ErrorHandler
+	autoAck   bool
+	retry     *RetryPolicy
 	wg        *sync.WaitGroup
 	unmarshal func([]byte) (*transport.Message, error)
 }
@@ -46,36 +66,29 @@ func (s *KafkaSubscriber) Topic() string {
 	return s.topic
 }
 
+// Unsubscribe 停止拉取, 等待当前批次处理完成后提交已确认的offset并退出消费组
 func (s *KafkaSubscriber) Unsubscribe() error {
-	ctx := context.Background()
-	s.client.Close()
-	s.stop <- struct{}{}
-	s.wg.Wait() // 等待循环推出
-	for {       // 处理剩余记录
-		fetches := s.client.PollRecords(ctx, 100)
-		s.fire(fetches)
-		if fetches.IsClientClosed() {
-			break
-		}
-	}
+	s.cancel()
+	s.wg.Wait() // 等待循环退出
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	s.offsets.commit(ctx)
+	s.client.CloseAllowingRebalance()
 	return nil
-
 }
 
 func (s *KafkaSubscriber) start() {
-	ctx := context.Background()
-	s.wg.Add(1)
-STOP:
+	defer s.wg.Done()
 	for {
-		select {
-		case <-s.stop:
-			break STOP
-		default:
-			fetches := s.client.PollRecords(ctx, 100)
-			s.fire(fetches)
+		fetches := s.client.PollRecords(s.ctx, 100)
+		if fetches.IsClientClosed() || s.ctx.Err() != nil {
+			// 已拉取的消息不处理, 未确认由其他消费者重新投递
+			return
 		}
+		s.fire(fetches)
+		// 批次处理完成后才允许分区回收
+		s.client.AllowRebalance()
 	}
-	s.wg.Done()
 }
 
 func (s *KafkaSubscriber) fire(fetches kgo.Fetches) int {
@@ -84,13 +97,19 @@ func (s *KafkaSubscriber) fire(fetches kgo.Fetches) int {
 		return 0
 	}
 	for _, record := range records {
+		p := s.offsets.track(record)
 		ctx, msg, err := Decode(record, s.unmarshal)
 		if err != nil {
-			s.fallback(ctx, "kafka.decode", record, err)
+			s.fallback(ctx, "kafka.decode", kafkaRecord(record), err)
+			// 无法解析的消息重新投递也无法处理, 直接确认
+			s.offsets.ack(p, record)
 			continue
 		}
 		event := &kafkaEvent{
-			msg: msg,
+			msg:       msg,
+			record:    record,
+			partition: p,
+			offsets:   s.offsets,
 		}
 
 		var span oteltrace.Span
@@ -99,9 +118,20 @@ func (s *KafkaSubscriber) fire(fetches kgo.Fetches) int {
 				attribute.String("endpoint", msg.Header[transport.Endpoint]),
 			),
 		)
-		if err = s.handler(ctx, event); err != nil {
+		attempts, err := s.handle(ctx, event)
+		if err != nil {
 			span.RecordError(err)
-			s.fallback(ctx, "kafka.handler", record, err)
+			s.fallback(ctx, "kafka.handler", kafkaRecord(record), err)
+			if s.retry != nil && s.ctx.Err() == nil {
+				// 进入死信后确认原消息, 发布失败则不确认等待重新投递
+				if err = s.deadLetter(ctx, record, attempts, err); err != nil {
+					s.fallback(ctx, "kafka.deadletter", kafkaRecord(record), err)
+				} else {
+					_ = event.Ack()
+				}
+			}
+		} else if s.autoAck {
+			_ = event.Ack()
 		}
 
 		span.End()
@@ -110,6 +140,35 @@ func (s *KafkaSubscriber) fire(fetches kgo.Fetches) int {
 	return len(records)
 }
 
+// handle 按重试策略处理消息, 返回处理次数与最后一次错误
+func (s *KafkaSubscriber) handle(ctx context.Context, event *kafkaEvent) (int, error) {
+	for attempt := 1; ; attempt++ {
+		err := s.handler(ctx, event)
+		if err == nil || s.retry == nil || !s.retry.retry(attempt) {
+			return attempt, err
+		}
+		select {
+		case <-time.After(s.retry.delay(attempt)):
+		case <-s.ctx.Done():
+			return attempt, err
+		}
+	}
+}
+
+func kafkaRecord(record *kgo.Record) *Record {
+	headers := make(map[string]string, len(record.Headers))
+	for _, header := range record.Headers {
+		headers[header.Key] = string(header.Value)
+	}
+	return &Record{
+		Topic:   record.Topic,
+		Key:     record.Key,
+		Value:   record.Value,
+		Headers: headers,
+		Raw:     record,
+	}
+}
+
 func Decode(record *kgo.Record, unmarshal ...SubscribeUnmarshal) (ctx context.Context, msg *transport.Message, err error) {
 	if len(unmarshal) > 0 {
 		msg, err = unmarshal[0](record.Value)
@@ -25,6 +25,8 @@ type Request interface {
 	Body() interface{}
 	// service version fileter
 	Version() *Version
+	// node version constraint, e.g. ">=2.3 <3"
+	Constraint() *Constraint
 }
 
 type Response struct {
@@ -45,12 +47,13 @@ type Stream interface {
 }
 
 type Target struct {
-	ID        string     // primary key
-	Method    string     // http method
-	Host      string     // http host
-	Service   string     // service
-	Endpoint  string     // service endpoint
-	Version   *Version   // service version
-	Protocols *Protocols // request protocols
-	Query     url.Values // request query parameters
+	ID         string      // primary key
+	Method     string      // http method
+	Host       string      // http host
+	Service    string      // service
+	Endpoint   string      // service endpoint
+	Version    *Version    // service version
+	Constraint *Constraint // node version constraint
+	Protocols  *Protocols  // request protocols
+	Query      url.Values  // request query parameters
 }
@@ -0,0 +1,27 @@
+package client
+
+import (
+	"context"
+	"github.com/lolizeppelin/micro"
+	"net/http"
+)
+
+/*
+UpdateNodeMetadata 调用节点内置Admin组件更新节点元数据(服务端需WithAdmin)
+Admin组件为内部rpc, 以内部请求调用
+e.g 设置权重 {"weight": "50"}, 下线 {"status": "draining"}, 空值删除
+*/
+func UpdateNodeMetadata(ctx context.Context, c Client, service, node string, md map[string]string) (map[string]string, error) {
+	protocols := &micro.Protocols{Reqeust: "application/grpc+json", Response: "application/grpc+json"}
+	req := NewRequest(micro.Target{
+		Method:    http.MethodPost,
+		Service:   service,
+		Endpoint:  micro.AdminMetadata,
+		Protocols: protocols,
+	}, &micro.NodeMetadata{Node: node, Metadata: md})
+	result := new(micro.NodeMetadata)
+	if err := c.RPC(ctx, req, &micro.Response{Body: result}, WithNode(node), WitInternal(true)); err != nil {
+		return nil, err
+	}
+	return result.Metadata, nil
+}
@@ -29,6 +29,7 @@ type Client interface {
 	RPC(ctx context.Context, req micro.Request, res *micro.Response, opts ...CallOption) error
 	Stream(ctx context.Context, req micro.Request, opts ...CallOption) (micro.Stream, error)
 	Publish(ctx context.Context, req micro.Request, opts ...CallOption) error
+	Batch(ctx context.Context, reqs []micro.Request, opts ...CallOption) ([]*BatchResult, error)
 	Name() string
 }
 
@@ -123,6 +124,10 @@ func (r *rpcRequest) Version() *micro.Version {
 	return r.target.Version
 }
 
+func (r *rpcRequest) Constraint() *micro.Constraint {
+	return r.target.Constraint
+}
+
 func (r *rpcRequest) Body() interface{} {
 	return r.body
 }
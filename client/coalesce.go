@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/codec"
+	"github.com/lolizeppelin/micro/transport"
+	"github.com/lolizeppelin/micro/utils"
+	"golang.org/x/sync/singleflight"
+	"net/http"
+	"strings"
+)
+
+type coalesceWrapper struct {
+	Client
+	sg singleflight.Group
+}
+
+// readOnly GET请求
+func readOnly(req micro.Request) bool {
+	return req.Method() == http.MethodGet
+}
+
+/*
+coalesceKey 合并key, 包含endpoint/主键/query/body哈希, 认证相关头以及节点选择条件
+自定义节点过滤器无法比较, 设置时不合并
+*/
+func coalesceKey(ctx context.Context, req micro.Request, opts ...CallOption) (string, bool) {
+	var callOpts CallOptions
+	for _, o := range opts {
+		o(&callOpts)
+	}
+	if len(callOpts.Filters) > 0 {
+		return "", false
+	}
+	protocols := req.Protocols()
+	body, err := codec.Marshal(protocols.Reqeust, req.Body())
+	if err != nil {
+		return "", false
+	}
+	var version string
+	if v := req.Version(); v != nil {
+		version = v.Version()
+	}
+	token, _ := transport.ContextGet(ctx, micro.TokenHeader)
+	tenant, _ := transport.ContextGet(ctx, micro.Tenant)
+	pin, _ := transport.ContextGet(ctx, micro.VersionHeader)
+	labels, _ := transport.ContextGet(ctx, micro.LabelHeader)
+	node, _ := transport.ContextGet(ctx, micro.NodeHeader)
+	parts := []string{
+		req.Service(), version, req.Endpoint(), req.PrimaryKey(),
+		protocols.Reqeust, protocols.Response, req.Query().Encode(),
+		utils.Sha256Sum(body), utils.Sha256Sum([]byte(token)), tenant,
+		callOpts.Node, callOpts.LabelSelector, pin, labels, node,
+	}
+	return strings.Join(parts, "\n"), true
+}
+
+func (c *coalesceWrapper) Call(ctx context.Context, req micro.Request, opts ...CallOption) (*transport.Message, error) {
+	if !readOnly(req) {
+		return c.Client.Call(ctx, req, opts...)
+	}
+	key, ok := coalesceKey(ctx, req, opts...)
+	if !ok {
+		return c.Client.Call(ctx, req, opts...)
+	}
+	// 上游请求不随首个调用方取消, 各调用方按自身ctx等待
+	upstream := context.WithoutCancel(ctx)
+	ch := c.sg.DoChan(key, func() (interface{}, error) {
+		return c.Client.Call(upstream, req, opts...)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return copyMessage(res.Val.(*transport.Message)), nil
+	}
+}
+
+func (c *coalesceWrapper) RPC(ctx context.Context, req micro.Request, res *micro.Response, opts ...CallOption) error {
+	if !readOnly(req) {
+		return c.Client.RPC(ctx, req, res, opts...)
+	}
+	msg, err := c.Call(ctx, req, opts...)
+	if err != nil {
+		return err
+	}
+	res.Headers = msg.Header
+	return codec.Unmarshal(req.Protocols().Response, msg.Body, res)
+}
+
+// copyMessage 每个调用方获得独立副本
+func copyMessage(msg *transport.Message) *transport.Message {
+	if msg == nil {
+		return nil
+	}
+	cp := &transport.Message{
+		Header: make(map[string]string, len(msg.Header)),
+		Body:   append([]byte(nil), msg.Body...),
+	}
+	for k, v := range msg.Header {
+		cp.Header[k] = v
+	}
+	if msg.Query != nil {
+		cp.Query = make(map[string][]string, len(msg.Query))
+		for k, v := range msg.Query {
+			cp.Query[k] = append([]string(nil), v...)
+		}
+	}
+	return cp
+}
+
+// Coalesce wraps a client to merge identical in-flight read-only calls into one upstream request.
+func Coalesce() Wrapper {
+	return func(c Client) Client {
+		return &coalesceWrapper{Client: c}
+	}
+}
@@ -0,0 +1,80 @@
+package client
+
+import (
+	"context"
+	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/transport"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type countClient struct {
+	Client
+	calls int32
+}
+
+func (c *countClient) Call(_ context.Context, _ micro.Request, _ ...CallOption) (*transport.Message, error) {
+	atomic.AddInt32(&c.calls, 1)
+	time.Sleep(20 * time.Millisecond)
+	return &transport.Message{Header: map[string]string{"k": "v"}, Body: []byte("body")}, nil
+}
+
+func TestCoalesce(t *testing.T) {
+	upstream := &countClient{}
+	c := Coalesce()(upstream)
+	protocols := &micro.Protocols{Reqeust: "application/grpc+json", Response: "application/grpc+json"}
+	get := NewRequest(micro.Target{Method: http.MethodGet, Service: "user", Endpoint: "User.money",
+		ID: "1", Protocols: protocols}, nil)
+
+	var wg sync.WaitGroup
+	messages := make([]*transport.Message, 5)
+	for i := range messages {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			messages[i], _ = c.Call(context.Background(), get)
+		}(i)
+	}
+	wg.Wait()
+	if upstream.calls != 1 {
+		t.Fatalf("identical reads not coalesced: %d", upstream.calls)
+	}
+	messages[0].Body[0] = 'x'
+	messages[0].Header["k"] = "x"
+	if string(messages[1].Body) != "body" || messages[1].Header["k"] != "v" {
+		t.Fatal("coalesced result shared between callers")
+	}
+
+	create := NewRequest(micro.Target{Method: http.MethodPost, Service: "user", Endpoint: "User.Create",
+		Protocols: protocols}, nil)
+	for i := 0; i < 2; i++ {
+		_, _ = c.Call(context.Background(), create)
+	}
+	if upstream.calls != 3 {
+		t.Fatalf("write calls should not be coalesced: %d", upstream.calls)
+	}
+}
+
+func TestCoalescePinnedNodes(t *testing.T) {
+	upstream := &countClient{}
+	c := Coalesce()(upstream)
+	protocols := &micro.Protocols{Reqeust: "application/grpc+json", Response: "application/grpc+json"}
+	get := NewRequest(micro.Target{Method: http.MethodGet, Service: "user", Endpoint: "User.money",
+		ID: "1", Protocols: protocols}, nil)
+
+	var wg sync.WaitGroup
+	for _, node := range []string{"001", "002"} {
+		wg.Add(1)
+		go func(node string) {
+			defer wg.Done()
+			_, _ = c.Call(context.Background(), get, WithNode(node))
+		}(node)
+	}
+	wg.Wait()
+	if upstream.calls != 2 {
+		t.Fatalf("calls pinned to different nodes coalesced: %d", upstream.calls)
+	}
+}
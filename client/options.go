@@ -161,6 +161,14 @@ func StreamTimeout(d time.Duration) Option {
 	}
 }
 
+// LabelSelector sets the default label selector expression used to filter nodes,
+// e.g. "zone in (a,b),tier!=batch,gpu".
+func LabelSelector(expr string) Option {
+	return func(o *Options) {
+		o.CallOptions.LabelSelector = expr
+	}
+}
+
 // DialTimeout sets the transport dial timeout.
 func DialTimeout(d time.Duration) Option {
 	return func(o *Options) {
@@ -185,6 +193,8 @@ type CallOptions struct {
 	Retry RetryFunc
 	// node filters
 	Filters []selector.Filter
+	// label selector expression of nodes
+	LabelSelector string
 	// Middleware for low level call func
 	CallWrappers []CallWrapper
 	// ConnectionTimeout of one request to the server.
@@ -210,6 +220,14 @@ func WithSelectFilters(filters ...selector.Filter) CallOption {
 	}
 }
 
+// WithLabelSelector is a CallOption which overrides the label selector
+// expression set in Options.CallOptions.
+func WithLabelSelector(expr string) CallOption {
+	return func(o *CallOptions) {
+		o.LabelSelector = expr
+	}
+}
+
 // WithCallWrapper is a CallOption which adds to the existing CallFunc wrappers.
 func WithCallWrapper(cw ...CallWrapper) CallOption {
 	return func(o *CallOptions) {
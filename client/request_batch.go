@@ -0,0 +1,164 @@
+package client
+
+import (
+	"context"
+	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/codec"
+	exc "github.com/lolizeppelin/micro/errors"
+	"github.com/lolizeppelin/micro/log"
+	"github.com/lolizeppelin/micro/tracing"
+	"github.com/lolizeppelin/micro/transport"
+	"github.com/lolizeppelin/micro/utils"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BatchResult is the result of one batch item, in request order.
+type BatchResult struct {
+	Message *transport.Message
+	Err     error
+}
+
+/*
+Batch 批量请求
+1. 每个请求独立选择节点, 按节点分组, 每组以一个transport.Message发送
+2. 不重试, 节点调用失败时该组全部请求返回同一错误
+*/
+func (r *rpcClient) Batch(ctx context.Context, requests []micro.Request, opts ...CallOption) ([]*BatchResult, error) {
+	callOpts := r.opts.CallOptions
+	for _, opt := range opts {
+		opt(&callOpts)
+	}
+
+	var span oteltrace.Span
+	tracer := tracing.GetTracer(CallScope, _version)
+	ctx, span = tracer.Start(ctx, "batch",
+		oteltrace.WithSpanKind(oteltrace.SpanKindInternal),
+		oteltrace.WithAttributes(
+			attribute.String("rpc.transport", r.Name()),
+			attribute.Int("items", len(requests)),
+		))
+	defer span.End()
+
+	if d, ok := ctx.Deadline(); ok {
+		opt := WithRequestTimeout(time.Until(d))
+		opt(&callOpts)
+	} else {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, callOpts.RequestTimeout)
+		defer cancel()
+	}
+
+	results := make([]*BatchResult, len(requests))
+	nodes := make(map[string]*micro.Node)
+	picked := make([]*micro.Node, len(requests)) // 每个请求选择的节点, 用于Mark
+	groups := make(map[string][]int)
+	for i, request := range requests {
+		next, err := r.next(ctx, request, callOpts)
+		if err != nil {
+			results[i] = &BatchResult{Err: err}
+			continue
+		}
+		node, err := next()
+		if err != nil {
+			results[i] = &BatchResult{Err: err}
+			continue
+		}
+		picked[i] = node
+		nodes[node.Address] = node
+		groups[node.Address] = append(groups[node.Address], i)
+	}
+	span.AddEvent("batch", oteltrace.WithAttributes(attribute.Int("nodes", len(groups))))
+
+	var wg sync.WaitGroup
+	for address, indexes := range groups {
+		wg.Add(1)
+		go func(node *micro.Node, indexes []int) {
+			defer wg.Done()
+			items := make([]micro.Request, len(indexes))
+			for i, index := range indexes {
+				items[i] = requests[index]
+			}
+			messages, err := r.batch(ctx, node, items, callOpts)
+			for i, index := range indexes {
+				r.opts.Selector.Mark(requests[index].Service(), picked[index], err)
+				if err != nil {
+					results[index] = &BatchResult{Err: err}
+					continue
+				}
+				result := &BatchResult{Message: messages[i]}
+				if e, ok := messages[i].Header[transport.Error]; ok && e != "" {
+					result.Err = exc.Parse(e)
+				}
+				results[index] = result
+			}
+		}(nodes[address], indexes)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+func (r *rpcClient) batch(ctx context.Context, node *micro.Node,
+	requests []micro.Request, opts CallOptions) (msgs []*transport.Message, err error) {
+
+	items := make([]*transport.Message, len(requests))
+	for i, request := range requests {
+		protocol := request.Protocols()
+		body, e := codec.Marshal(protocol.Reqeust, request.Body())
+		if e != nil {
+			return nil, exc.BadRequest("micro.rpc.batch", e.Error())
+		}
+		items[i] = &transport.Message{
+			Header: map[string]string{
+				micro.ContentType:  protocol.Reqeust,
+				micro.Accept:       protocol.Response,
+				micro.Host:         request.Host(),
+				micro.PrimaryKey:   request.PrimaryKey(),
+				transport.Service:  request.Service(),
+				transport.Method:   request.Method(),
+				transport.Endpoint: request.Endpoint(),
+			},
+			Query: request.Query(),
+			Body:  body,
+		}
+	}
+	body, err := transport.EncodeBatch(items)
+	if err != nil {
+		return nil, exc.BadRequest("micro.rpc.batch", err.Error())
+	}
+
+	headers := transport.CopyFromContext(ctx)
+	headers[transport.Batch] = "1"
+	headers["Timeout"] = utils.UnsafeToString(opts.RequestTimeout / time.Second)
+	headers[transport.ID] = utils.UnsafeToString(atomic.AddUint64(&r.seq, 1) - 1)
+
+	c, err := r.pool.Get(node.Address, opts.DialTimeout)
+	if err != nil {
+		return nil, exc.InternalServerError("micro.client.batch", "connection error: %v", err)
+	}
+	defer func() {
+		if e := r.pool.Release(c, err); e != nil {
+			log.Errorf(ctx, "failed to release connection %v", e.Error())
+		}
+	}()
+
+	msg, err := c.Call(ctx, &transport.Message{
+		Header: headers,
+		Body:   body,
+	})
+	if err != nil {
+		return nil, exc.ClientError("micro.client.batch", err)
+	}
+	msgs, err = transport.DecodeBatch(msg.Body)
+	if err != nil {
+		return nil, exc.InternalServerError("micro.client.batch", "decode batch response failed: %v", err)
+	}
+	if len(msgs) != len(requests) {
+		return nil, exc.InternalServerError("micro.client.batch",
+			"batch response count %d not match %d", len(msgs), len(requests))
+	}
+	return msgs, nil
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/lolizeppelin/micro/codec"
 	exc "github.com/lolizeppelin/micro/errors"
 	"github.com/lolizeppelin/micro/registry"
+	"github.com/lolizeppelin/micro/selector"
 	"github.com/lolizeppelin/micro/tracing"
 	"github.com/lolizeppelin/micro/transport"
 	"go.opentelemetry.io/otel/attribute"
@@ -19,24 +20,31 @@ func (r *rpcClient) publish(ctx context.Context, request micro.Request, opts ...
 	for _, opt := range opts {
 		opt(&callOpts)
 	}
+	protocol := request.Protocols()
+	// 先编码, 选择节点后不再提前返回
+	b, err := codec.Marshal(protocol.Reqeust, request.Body())
+	if err != nil {
+		return exc.InternalServerError("micro.rpc.publish", err.Error())
+	}
+
 	node := callOpts.Node
 	// 有node id或版本限定,通过过滤器筛选node,设置节点
+	var selected *micro.Node
 	if node == "" && request.Version() != nil {
-		next, err := r.next(ctx, request, callOpts)
+		var next selector.Next
+		next, err = r.next(ctx, request, callOpts)
 		if err != nil {
 			return err
 		}
-		var n *micro.Node
-		n, err = next()
+		selected, err = next()
 		if err != nil {
 			return err
 		}
-		node = n.Id
+		node = selected.Id
 	}
 
-	topic := registry.Topic(request.Service(), request.Version(), node)
+	topic := registry.Topic(registry.Namespace(r.opts.Registry), request.Service(), request.Version(), node)
 	headers := transport.CopyFromContext(ctx)
-	protocol := request.Protocols()
 	headers[micro.ContentType] = protocol.Reqeust
 	headers[micro.Host] = request.Host()
 	headers[transport.Service] = request.Service()
@@ -47,10 +55,6 @@ func (r *rpcClient) publish(ctx context.Context, request micro.Request, opts ...
 		Header: headers,
 	}
 
-	b, err := codec.Marshal(protocol.Reqeust, request.Body())
-	if err != nil {
-		return exc.InternalServerError("micro.rpc.publish", err.Error())
-	}
 	// set the body
 	msg.Body = b
 
@@ -68,6 +72,10 @@ func (r *rpcClient) publish(ctx context.Context, request micro.Request, opts ...
 
 	defer span.End()
 
-	return r.opts.Broker.Publish(ctx, topic, msg)
+	err = r.opts.Broker.Publish(ctx, topic, msg)
+	if selected != nil { // 回填选择结果, 释放熔断器放行
+		r.opts.Selector.Mark(request.Service(), selected, err)
+	}
+	return err
 
 }
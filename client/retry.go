@@ -5,6 +5,7 @@ import (
 	"github.com/lolizeppelin/micro"
 
 	exc "github.com/lolizeppelin/micro/errors"
+	"time"
 )
 
 // note that returning either false or a non-nil error will result in the call not being retried.
@@ -15,7 +16,7 @@ func RetryAlways(ctx context.Context, req micro.Request, retryCount int, err err
 	return true, nil
 }
 
-// RetryOnError retries a request on a 500 or timeout error.
+// RetryOnError retries a request on a timeout error, or a 429 error after its retry-after hint.
 func RetryOnError(ctx context.Context, req micro.Request, retryCount int, err error) (bool, error) {
 	if err == nil {
 		return false, nil
@@ -31,7 +32,28 @@ func RetryOnError(ctx context.Context, req micro.Request, retryCount int, err er
 	// logic error that should be handled by the user.
 	case 408:
 		return true, nil
+	case 429:
+		return waitRetryAfter(ctx, err), nil
 	default:
 		return false, nil
 	}
 }
+
+// waitRetryAfter waits for the retry-after hint, false if the context expires first.
+func waitRetryAfter(ctx context.Context, err error) bool {
+	retry, _ := exc.RetryAfter(err)
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < retry {
+		return false
+	}
+	if retry <= 0 {
+		return true
+	}
+	timer := time.NewTimer(retry)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
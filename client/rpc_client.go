@@ -3,9 +3,11 @@ package client
 import (
 	"context"
 	"fmt"
+	"github.com/google/uuid"
 	"github.com/lolizeppelin/micro"
 	"github.com/lolizeppelin/micro/codec"
 	exc "github.com/lolizeppelin/micro/errors"
+	"github.com/lolizeppelin/micro/idempotency"
 	"github.com/lolizeppelin/micro/log"
 	"github.com/lolizeppelin/micro/tracing"
 	"github.com/lolizeppelin/micro/transport"
@@ -53,8 +55,6 @@ func (r *rpcClient) Call(ctx context.Context, request micro.Request, opts ...Cal
 	tracer := tracing.GetTracer(CallScope, _version)
 	name := fmt.Sprintf("%s.%s.%s", request.Method(), request.Service(), request.Endpoint())
 
-	defer span.End()
-
 	// check if we already have a deadline
 	d, ok := ctx.Deadline()
 	if !ok {
@@ -87,6 +87,7 @@ func (r *rpcClient) Call(ctx context.Context, request micro.Request, opts ...Cal
 		opt := WithRequestTimeout(time.Until(d))
 		opt(&callOpts)
 	}
+	defer span.End()
 
 	// should we noop right here?
 	select {
@@ -95,6 +96,13 @@ func (r *rpcClient) Call(ctx context.Context, request micro.Request, opts ...Cal
 	default:
 	}
 
+	// retries of the same logical call to a non-idempotent endpoint share one idempotency key
+	if callOpts.Retries > 0 && !idempotency.Idempotent(request.Method()) {
+		if _, ok := transport.ContextGet(ctx, micro.IdempotencyKey); !ok {
+			ctx = transport.MergeContext(ctx, transport.Metadata{micro.IdempotencyKey: uuid.New().String()}, false)
+		}
+	}
+
 	// make copy of call method
 	rcall := r.call
 
@@ -7,6 +7,7 @@ import (
 	exc "github.com/lolizeppelin/micro/errors"
 	"github.com/lolizeppelin/micro/selector"
 	"github.com/lolizeppelin/micro/tracing"
+	"github.com/lolizeppelin/micro/transport"
 	"github.com/lolizeppelin/micro/utils"
 	"go.opentelemetry.io/otel/attribute"
 	oteltrace "go.opentelemetry.io/otel/trace"
@@ -18,9 +19,14 @@ func (r *rpcClient) next(ctx context.Context, request micro.Request, opts CallOp
 	endpoint := request.Endpoint()
 	filters := opts.Filters
 	version := request.Version()
+	constraint := request.Constraint()
 	protocols := request.Protocols()
 
 	var span oteltrace.Span
+	var v string
+	if version != nil {
+		v = version.Version()
+	}
 	tracer := tracing.GetTracer(CallScope, _version)
 	ctx, span = tracer.Start(ctx, "node.selector",
 		oteltrace.WithSpanKind(oteltrace.SpanKindInternal),
@@ -28,7 +34,7 @@ func (r *rpcClient) next(ctx context.Context, request micro.Request, opts CallOp
 			attribute.String("name", r.opts.Selector.Name()),
 			attribute.String("endpoint", request.Endpoint()),
 			attribute.String("name", r.opts.Selector.Name()),
-			attribute.String("version", request.Version().Version()),
+			attribute.String("version", v),
 		),
 	)
 	if opts.Node != "" {
@@ -66,8 +72,8 @@ func (r *rpcClient) next(ctx context.Context, request micro.Request, opts CallOp
 				if ep.Internal && !opts.Internal { // 屏蔽内部rpc请求
 					return nil, exc.Forbidden("micro.client.selector", "disabled request")
 				}
-				pk := request.PrimaryKey() == ""
-				if (ep.PrimaryKey && !pk) || (pk && !ep.PrimaryKey) {
+				pk := request.PrimaryKey() != ""
+				if pk != ep.PrimaryKey { // 需要主键的endpoint必须携带主键, 反之不能携带
 					return nil, exc.BadRequest("micro.client.selector", "request path param error")
 				}
 				//for _, ep := range s.Endpoints {
@@ -92,43 +98,62 @@ func (r *rpcClient) next(ctx context.Context, request micro.Request, opts CallOp
 				//	return nil, micro.ErrSelectEndpointNotFound
 				//}
 				// 节点过滤
-				if opts.Node != "" {
-					service := &micro.Service{
-						Name:      s.Name,
-						Version:   s.Version,
-						Metadata:  s.Metadata,
-						Endpoints: s.Endpoints,
+				if opts.Node == "" && version == nil && constraint == nil {
+					matched = append(matched, s)
+					continue
+				}
+				service := &micro.Service{
+					Name:      s.Name,
+					Version:   s.Version,
+					Metadata:  s.Metadata,
+					Endpoints: s.Endpoints,
+				}
+				for _, node := range s.Nodes {
+					if opts.Node != "" && node.Id == opts.Node { // 节点ID过滤, 跳过节点状态过滤
+						return []*micro.Service{selector.Pin(s, node)}, nil
 					}
-					for _, node := range s.Nodes {
-						if opts.Node != "" && node.Id == opts.Node { // 节点ID过滤
-							service.Nodes = append(service.Nodes, node)
-							return []*micro.Service{service}, nil
-						} else { // 节点版本过滤
-							if version != nil {
-								if node.Max != nil && version.Compare(*node.Min) > 0 { // 超过最大兼容
-									continue
-								}
-								if node.Min != nil && version.Compare(*node.Min) < 0 { // 低于最小兼容
-									continue
-								}
-								if node.Max == nil && node.Min == nil && version.Compare(node.Version) != 0 { // 无兼容,不匹配
-									continue
-								}
-							}
-							service.Nodes = append(service.Nodes, node)
-						}
+					// 节点版本兼容过滤
+					if version != nil && !node.Compatible(*version) {
+						continue
 					}
-					if len(service.Nodes) > 0 {
-						matched = append(matched, service)
+					// 节点版本约束过滤
+					if constraint != nil && !constraint.Check(node.Version) {
+						continue
 					}
-				} else {
-					matched = append(matched, s)
+					service.Nodes = append(service.Nodes, node)
+				}
+				if len(service.Nodes) > 0 {
+					matched = append(matched, service)
 				}
 			}
 
 			return matched, nil
 		})
 
+	// 请求头限定版本
+	if pin, ok := transport.ContextGet(ctx, micro.VersionHeader); ok && pin != "" {
+		span.AddEvent("selector", oteltrace.WithAttributes(attribute.String("pin", pin)))
+		filters = append(filters, selector.VersionFilter(pin))
+	}
+
+	// 标签选择表达式
+	labels := []string{opts.LabelSelector}
+	if expr, ok := transport.ContextGet(ctx, micro.LabelHeader); ok {
+		labels = append(labels, expr)
+	}
+	for _, expr := range labels {
+		if expr == "" {
+			continue
+		}
+		filter, err := selector.LabelFilter(expr)
+		if err != nil {
+			span.RecordError(err)
+			return nil, exc.BadRequest("micro.client.selector", err.Error())
+		}
+		span.AddEvent("selector", oteltrace.WithAttributes(attribute.String("labels", expr)))
+		filters = append(filters, filter)
+	}
+
 	service := request.Service()
 	// get next nodes from the selector
 	next, err := r.opts.Selector.Select(service, filters...)
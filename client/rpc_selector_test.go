@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/selector"
+	"net/http"
+	"testing"
+)
+
+type staticSelector struct {
+	selector.Selector
+	services []*micro.Service
+}
+
+func (s *staticSelector) Select(_ string, filters ...selector.Filter) (selector.Next, error) {
+	services := s.services
+	var err error
+	for _, filter := range filters {
+		if services, err = filter(services); err != nil {
+			return nil, err
+		}
+	}
+	return selector.RoundRobin(services), nil
+}
+
+func (s *staticSelector) Name() string { return "static" }
+
+func TestNextCompatible(t *testing.T) {
+	protocols := &micro.Protocols{Reqeust: "application/grpc+json", Response: "application/grpc+json"}
+	endpoint := &micro.Endpoint{Name: "User.money", Metadata: map[string]string{"req": "json", "res": "json"}}
+	r := &rpcClient{opts: Options{Selector: &staticSelector{services: []*micro.Service{{
+		Name:      "user",
+		Version:   2,
+		Endpoints: map[string]*micro.Endpoint{"User.money": endpoint},
+		Nodes: []*micro.Node{
+			{Id: "old", Version: micro.Version{Major: 2, Minor: 3}},
+			{Id: "new", Version: micro.Version{Major: 2, Minor: 4}, Min: &micro.Version{Major: 2, Minor: 4}},
+		},
+	}}}}}
+	req := NewRequest(micro.Target{Method: http.MethodPost, Service: "user", Endpoint: "User.money",
+		Version: &micro.Version{Major: 2, Minor: 3}, Protocols: protocols}, nil)
+
+	// 未指定节点时跳过不兼容请求版本的节点
+	next, err := r.next(context.Background(), req, CallOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if node, _ := next(); node == nil || node.Id != "old" {
+			t.Fatalf("incompatible node selected: %v", node)
+		}
+	}
+}
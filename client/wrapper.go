@@ -49,6 +49,11 @@ func (f *fromServiceWrapper) Publish(ctx context.Context, req micro.Request, opt
 	return f.Client.Publish(ctx, req, opts...)
 }
 
+func (f *fromServiceWrapper) Batch(ctx context.Context, reqs []micro.Request, opts ...CallOption) ([]*BatchResult, error) {
+	ctx = f.setHeaders(ctx)
+	return f.Client.Batch(ctx, reqs, opts...)
+}
+
 // FromService wraps a client to inject service and auth metadata.
 func FromService(name string, c Client) Client {
 	return &fromServiceWrapper{
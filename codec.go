@@ -24,16 +24,21 @@ var (
 )
 
 const (
-	NodeHeader  = "X-Node-Id"      // 限定 node
-	TokenHeader = "X-Auth-Token"   // 认证头
-	TokenScope  = "X-Token-Scope"  // token范围
-	TokenTenant = "X-Token-Tenant" // token限定租户范围
-
-	ContentType = "Content-Type"
-	Accept      = "Accept"
-	Host        = "Host"
-	Tenant      = "Tenant"
-	PrimaryKey  = "PrimaryKey"
+	NodeHeader    = "X-Node-Id"         // 限定 node
+	VersionHeader = "X-Service-Version" // 限定版本 e.g 2.4 / 2.4.x
+	LabelHeader   = "X-Node-Selector"   // 节点标签选择表达式 e.g zone in (a,b),tier!=batch
+	TokenHeader   = "X-Auth-Token"      // 认证头
+	TokenScope    = "X-Token-Scope"     // token范围
+	TokenTenant   = "X-Token-Tenant"    // token限定租户范围
+	TokenSubject  = "X-Token-Subject"   // token主体(由认证网关校验token后注入)
+
+	ContentType    = "Content-Type"
+	RetryAfter     = "Retry-After"
+	IdempotencyKey = "Idempotency-Key" // 同一逻辑调用(含重试)共享的幂等key
+	Accept         = "Accept"
+	Host           = "Host"
+	Tenant         = "Tenant"
+	PrimaryKey     = "PrimaryKey"
 )
 
 func MatchCodec(protocol, codec string) bool {
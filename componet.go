@@ -55,6 +55,15 @@ type Component interface {
 	Hooks(method string) []PreExecuteHook
 }
 
+/*
+RateLimited 组件可选实现, 声明方法的限流规则
+返回 原始方法名 -> 限流声明 e.g {"Get": "rate=100,burst=200,key=tenant"}
+声明格式参考 limiter.Rule
+*/
+type RateLimited interface {
+	RateLimits() map[string]string
+}
+
 /*
 ComponentBase 通用组件继承
 */
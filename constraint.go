@@ -0,0 +1,180 @@
+package micro
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/lolizeppelin/micro/utils"
+	"hash/fnv"
+	"strings"
+)
+
+// constraintTerm 单个版本比较条件, parts为参与比较的版本段数(部分版本号 e.g 2.3 仅比较两段)
+type constraintTerm struct {
+	op      string
+	version Version
+	parts   int
+}
+
+func (t constraintTerm) check(v Version) bool {
+	c := v.compareParts(t.version, t.parts)
+	switch t.op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	}
+	return false
+}
+
+/*
+Constraint 版本约束表达式
+1. 空格或逗号分隔的条件为且关系, "||" 分隔的条件组为或关系
+2. 支持操作符 = != > >= < <= ~ ^, 无操作符等同于 =
+3. 部分版本号只比较给出的段, 支持x/*通配 e.g 2.3 / 2.3.x 匹配全部2.3补丁版本
+4. ~2.4.1 等同于 >=2.4.1 <2.5, ^2.4.1 等同于 >=2.4.1 <3
+e.g ">=2.3 <3", "~2.4", "2.3.x || >=3.1"
+*/
+type Constraint struct {
+	expr   string
+	groups [][]constraintTerm
+}
+
+// Check 版本是否满足约束
+func (c *Constraint) Check(v Version) bool {
+	for _, group := range c.groups {
+		matched := true
+		for _, term := range group {
+			if !term.check(v) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Constraint) String() string {
+	return c.expr
+}
+
+// Hash 注册记录哈希时按表达式计算(hashstructure不读取未导出字段)
+func (c *Constraint) Hash() (uint64, error) {
+	h := fnv.New64a()
+	h.Write([]byte(c.expr))
+	return h.Sum64(), nil
+}
+
+func (c *Constraint) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.expr)
+}
+
+func (c *Constraint) UnmarshalJSON(b []byte) error {
+	var expr string
+	if err := json.Unmarshal(b, &expr); err != nil {
+		return err
+	}
+	parsed, err := NewConstraint(expr)
+	if err != nil {
+		return err
+	}
+	*c = *parsed
+	return nil
+}
+
+// parsePartial 解析部分版本号, 返回有效段数
+func parsePartial(s string) (Version, int, error) {
+	var v Version
+	s = strings.TrimPrefix(s, "v")
+	if s == "" {
+		return v, 0, fmt.Errorf("version constraint: empty version")
+	}
+	segments := strings.Split(s, ".")
+	if len(segments) > 3 {
+		return v, 0, fmt.Errorf("version constraint: version %q has more than 3 parts", s)
+	}
+	values := []*int{&v.Major, &v.Minor, &v.Patch}
+	parts := 0
+	for i, segment := range segments {
+		if segment == "x" || segment == "X" || segment == "*" {
+			break
+		}
+		n, err := utils.StringToInt(segment)
+		if err != nil || n < 0 {
+			return v, 0, fmt.Errorf("version constraint: invalid version %q", s)
+		}
+		*values[i] = n
+		parts = i + 1
+	}
+	return v, parts, nil
+}
+
+func parseTerm(s string) ([]constraintTerm, error) {
+	op := "="
+	for _, prefix := range []string{">=", "<=", "!=", ">", "<", "=", "~", "^"} {
+		if strings.HasPrefix(s, prefix) {
+			op = prefix
+			s = strings.TrimSpace(s[len(prefix):])
+			break
+		}
+	}
+	v, parts, err := parsePartial(s)
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case "~":
+		// ~2.4.1 => >=2.4.1 =2.4, ~2.4 => =2.4
+		if parts <= 2 {
+			return []constraintTerm{{"=", v, parts}}, nil
+		}
+		return []constraintTerm{{">=", v, 3}, {"=", v, 2}}, nil
+	case "^":
+		// ^2.4.1 => >=2.4.1 =2
+		return []constraintTerm{{">=", v, parts}, {"=", v, min(parts, 1)}}, nil
+	}
+	return []constraintTerm{{op, v, parts}}, nil
+}
+
+// NewConstraint 解析版本约束表达式
+func NewConstraint(expr string) (*Constraint, error) {
+	c := &Constraint{expr: strings.TrimSpace(expr)}
+	if c.expr == "" {
+		return nil, fmt.Errorf("version constraint: empty expression")
+	}
+	for _, or := range strings.Split(c.expr, "||") {
+		// 操作符与版本号之间允许空格
+		fields := strings.Fields(strings.ReplaceAll(or, ",", " "))
+		var group []constraintTerm
+		for i := 0; i < len(fields); i++ {
+			field := fields[i]
+			if strings.Trim(field, "<>=!~^") == "" {
+				if i+1 >= len(fields) {
+					return nil, fmt.Errorf("version constraint %q: operator %q without version", expr, field)
+				}
+				i++
+				field += fields[i]
+			}
+			terms, err := parseTerm(field)
+			if err != nil {
+				return nil, fmt.Errorf("%s in %q", err.Error(), expr)
+			}
+			group = append(group, terms...)
+		}
+		if len(group) == 0 {
+			return nil, fmt.Errorf("version constraint %q: empty condition", expr)
+		}
+		c.groups = append(c.groups, group)
+	}
+	return c, nil
+}
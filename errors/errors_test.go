@@ -4,6 +4,7 @@ import (
 	er "errors"
 	"net/http"
 	"testing"
+	"time"
 )
 
 func TestFromError(t *testing.T) {
@@ -161,3 +162,17 @@ func TestHasErrors(t *testing.T) {
 		t.Fatal("Expected errors")
 	}
 }
+
+func TestRetryAfter(t *testing.T) {
+	err := TooManyRequests("go.micro.test", 1500*time.Millisecond, "rate limit %s", "exceeded")
+	if FromError(err).Code != http.StatusTooManyRequests {
+		t.Fatalf("invalid code %v", err)
+	}
+	retry, ok := RetryAfter(er.New(err.Error()))
+	if !ok || retry != 2*time.Second {
+		t.Fatalf("invalid retry after %v %v", retry, ok)
+	}
+	if _, ok = RetryAfter(NotFound("go.micro.test", "retry-after=1")); ok {
+		t.Fatal("retry after should only parsed from 429/503")
+	}
+}
@@ -2,7 +2,15 @@ package errors
 
 import (
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	retryAfter = "retry-after"
 )
 
 // BadRequest generates a 400 error.
@@ -104,3 +112,30 @@ func ServiceUnavailable(id, format string, a ...interface{}) error {
 		Status: http.StatusText(503),
 	}
 }
+
+// TooManyRequests generates a 429 error, retry is appended to detail as retry-after hint.
+func TooManyRequests(id string, retry time.Duration, format string, a ...interface{}) error {
+	return &Error{
+		Id:     id,
+		Code:   http.StatusTooManyRequests,
+		Detail: fmt.Sprintf("%s; %s=%d", fmt.Sprintf(format, a...), retryAfter, int64(math.Ceil(retry.Seconds()))),
+		Status: http.StatusText(429),
+	}
+}
+
+// RetryAfter returns the retry-after hint carried by a 429/503 error.
+func RetryAfter(err error) (time.Duration, bool) {
+	e := FromError(err)
+	if e == nil || (e.Code != http.StatusTooManyRequests && e.Code != http.StatusServiceUnavailable) {
+		return 0, false
+	}
+	i := strings.LastIndex(e.Detail, retryAfter+"=")
+	if i < 0 {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(e.Detail[i+len(retryAfter)+1:], 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
@@ -61,6 +61,8 @@ func MicroStatusFromGrpcCode(code codes.Code) int32 {
 		return http.StatusInternalServerError
 	case codes.Unavailable:
 		return http.StatusServiceUnavailable
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
 	}
 	return http.StatusInternalServerError
 }
@@ -94,6 +96,8 @@ func GrpcCodeFromMicroError(err *Error) codes.Code {
 		return codes.Internal
 	case http.StatusServiceUnavailable:
 		return codes.Unavailable
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
 	}
 
 	return codes.Unknown
@@ -0,0 +1,161 @@
+package idempotency
+
+import (
+	"context"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultPrefix = "/micro/idempotency/"
+	PendingTTL    = time.Minute // 执行中占用key的租约时长
+)
+
+/*
+etcdStore 基于etcd的幂等存储, 多节点共享
+1. 占用时以空值创建key(租约为PendingTTL), 执行期间续约直到Complete, 节点异常退出后key随租约过期
+2. 重复请求watch key直到写入结果, key被删除(首个请求放弃)时重新占用
+*/
+type etcdStore struct {
+	prefix string
+	client *clientv3.Client
+
+	mu      sync.Mutex
+	pending map[string]*pendingLease // 执行中的key -> 续约中的租约
+}
+
+type pendingLease struct {
+	id     clientv3.LeaseID
+	cancel context.CancelFunc
+}
+
+func NewEtcdStore(client *clientv3.Client, prefix ...string) Store {
+	p := DefaultPrefix
+	if len(prefix) > 0 && prefix[0] != "" {
+		p = prefix[0]
+	}
+	if !strings.HasSuffix(p, "/") {
+		p = p + "/"
+	}
+	return &etcdStore{
+		prefix:  p,
+		client:  client,
+		pending: make(map[string]*pendingLease),
+	}
+}
+
+// keepalive 执行期间持续续约占用租约
+func (s *etcdStore) keepalive(key string, lease clientv3.LeaseID) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := s.client.KeepAlive(ctx, lease)
+	if err != nil {
+		cancel()
+		return
+	}
+	go func() {
+		for range ch {
+		}
+	}()
+	s.mu.Lock()
+	s.pending[key] = &pendingLease{id: lease, cancel: cancel}
+	s.mu.Unlock()
+}
+
+// release 停止续约并撤销占用租约
+func (s *etcdStore) release(key string) {
+	s.mu.Lock()
+	p, ok := s.pending[key]
+	delete(s.pending, key)
+	s.mu.Unlock()
+	if !ok {
+		return
+	}
+	p.cancel()
+	_, _ = s.client.Revoke(context.Background(), p.id)
+}
+
+func (s *etcdStore) grant(ctx context.Context, ttl time.Duration) (clientv3.LeaseID, error) {
+	lease, err := s.client.Grant(ctx, ttlSeconds(ttl))
+	if err != nil {
+		return 0, err
+	}
+	return lease.ID, nil
+}
+
+func (s *etcdStore) Acquire(ctx context.Context, key string, _ time.Duration) (*Result, bool, error) {
+	key = s.prefix + key
+	for {
+		lease, err := s.grant(ctx, PendingTTL)
+		if err != nil {
+			return nil, false, err
+		}
+		txn, err := s.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+			Then(clientv3.OpPut(key, "", clientv3.WithLease(lease))).
+			Else(clientv3.OpGet(key)).
+			Commit()
+		if err != nil {
+			return nil, false, err
+		}
+		if txn.Succeeded {
+			s.keepalive(key, lease)
+			return nil, true, nil
+		}
+		_, _ = s.client.Revoke(ctx, lease)
+
+		kvs := txn.Responses[0].GetResponseRange().Kvs
+		if len(kvs) == 0 {
+			continue
+		}
+		if len(kvs[0].Value) > 0 {
+			result, err := Unmarshal(kvs[0].Value)
+			return result, false, err
+		}
+		result, err := s.wait(ctx, key, kvs[0].ModRevision+1)
+		if err != nil || result != nil {
+			return result, false, err
+		}
+	}
+}
+
+// wait 等待首个请求写入结果, 返回nil表示key已释放
+func (s *etcdStore) wait(ctx context.Context, key string, rev int64) (*Result, error) {
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for resp := range s.client.Watch(wctx, key, clientv3.WithRev(rev)) {
+		if err := resp.Err(); err != nil {
+			return nil, err
+		}
+		for _, event := range resp.Events {
+			if event.Type == mvccpb.DELETE {
+				return nil, nil
+			}
+			if len(event.Kv.Value) > 0 {
+				return Unmarshal(event.Kv.Value)
+			}
+		}
+	}
+	return nil, ctx.Err()
+}
+
+func (s *etcdStore) Complete(ctx context.Context, key string, result *Result, ttl time.Duration) error {
+	key = s.prefix + key
+	defer s.release(key)
+	if result == nil {
+		_, err := s.client.Delete(ctx, key)
+		return err
+	}
+	value, err := result.Marshal()
+	if err != nil {
+		return err
+	}
+	lease, err := s.grant(ctx, ttl)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.Put(ctx, key, string(value), clientv3.WithLease(lease))
+	return err
+}
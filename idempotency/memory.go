@@ -0,0 +1,121 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SweepInterval 内存存储清理过期结果的最小间隔
+var SweepInterval = time.Minute
+
+type entry struct {
+	done    chan struct{}
+	result  *Result
+	expires time.Time
+}
+
+// completed 是否已完成, 未完成的key由首个请求的Complete释放
+func (e *entry) completed() bool {
+	select {
+	case <-e.done:
+		return true
+	default:
+		return false
+	}
+}
+
+/*
+memoryStore 进程内幂等存储
+1. 过期结果在Acquire时按SweepInterval间隔批量清理, 无后台协程
+2. 执行中的key不清理, 避免等待方永远等不到结果
+*/
+type memoryStore struct {
+	sync.Mutex
+	entries map[string]*entry
+	swept   time.Time
+}
+
+// NewMemoryStore 进程内幂等存储, 仅对同一节点上的重复请求生效
+func NewMemoryStore() Store {
+	return &memoryStore{
+		entries: make(map[string]*entry),
+		swept:   time.Now(),
+	}
+}
+
+func ttlDuration(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return DefaultTTL
+	}
+	return ttl
+}
+
+func ttlSeconds(ttl time.Duration) int64 {
+	seconds := int64(ttlDuration(ttl).Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+// sweep 清理已完成且过期的结果, 需持有锁
+func (s *memoryStore) sweep(now time.Time) {
+	if now.Sub(s.swept) < SweepInterval {
+		return
+	}
+	s.swept = now
+	for key, e := range s.entries {
+		if now.After(e.expires) && e.completed() {
+			delete(s.entries, key)
+		}
+	}
+}
+
+func (s *memoryStore) Acquire(ctx context.Context, key string, ttl time.Duration) (*Result, bool, error) {
+	for {
+		now := time.Now()
+		s.Lock()
+		s.sweep(now)
+		current, ok := s.entries[key]
+		if !ok || (now.After(current.expires) && current.completed()) {
+			s.entries[key] = &entry{done: make(chan struct{}), expires: now.Add(ttlDuration(ttl))}
+			s.Unlock()
+			return nil, true, nil
+		}
+		s.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		case <-current.done:
+		}
+		if current.result != nil {
+			return current.result, false, nil
+		}
+		// 首个请求放弃结果, 重新占用
+	}
+}
+
+func (s *memoryStore) Complete(_ context.Context, key string, result *Result, ttl time.Duration) error {
+	s.Lock()
+	e, ok := s.entries[key]
+	if !ok || e.completed() {
+		s.Unlock()
+		return nil
+	}
+	if result == nil {
+		delete(s.entries, key)
+	} else {
+		e.expires = time.Now().Add(ttlDuration(ttl))
+	}
+	e.result = result
+	close(e.done)
+	s.Unlock()
+	return nil
+}
+
+func (s *memoryStore) len() int {
+	s.Lock()
+	defer s.Unlock()
+	return len(s.entries)
+}
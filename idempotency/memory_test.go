@@ -0,0 +1,79 @@
+package idempotency
+
+import (
+	"context"
+	"github.com/lolizeppelin/micro"
+	exc "github.com/lolizeppelin/micro/errors"
+	"github.com/lolizeppelin/micro/transport"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+
+	if _, first, _ := store.Acquire(ctx, "a", time.Minute); !first {
+		t.Fatal("first acquire should execute")
+	}
+	var wg sync.WaitGroup
+	results := make([]*Result, 3)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _, _ = store.Acquire(ctx, "a", time.Minute)
+		}(i)
+	}
+	time.Sleep(10 * time.Millisecond)
+	_ = store.Complete(ctx, "a", NewResult([]byte("ok"), nil), time.Minute)
+	wg.Wait()
+	for i, result := range results {
+		if result == nil || string(result.Body) != "ok" {
+			t.Fatalf("duplicate %d not replayed: %v", i, result)
+		}
+	}
+
+	// 服务端错误释放key, 允许重试执行
+	_, _, _ = store.Acquire(ctx, "b", time.Minute)
+	_ = store.Complete(ctx, "b", NewResult(nil, exc.InternalServerError("test", "failed")), time.Minute)
+	if _, first, _ := store.Acquire(ctx, "b", time.Minute); !first {
+		t.Fatal("released key should execute again")
+	}
+	_ = store.Complete(ctx, "b", NewResult(nil, exc.Conflict("test", "exists")), time.Minute)
+	result, first, _ := store.Acquire(ctx, "b", time.Minute)
+	if first || result.Error == nil || result.Error.Code != 409 {
+		t.Fatalf("business error not replayed: %v", result)
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	interval := SweepInterval
+	SweepInterval = 0
+	defer func() { SweepInterval = interval }()
+
+	store := NewMemoryStore().(*memoryStore)
+	ctx := context.Background()
+	_, _, _ = store.Acquire(ctx, "a", time.Millisecond)
+	_ = store.Complete(ctx, "a", NewResult([]byte("ok"), nil), time.Millisecond)
+	_, _, _ = store.Acquire(ctx, "running", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	_, _, _ = store.Acquire(ctx, "b", time.Minute)
+	if n := store.len(); n != 2 {
+		t.Fatalf("expired result not swept, %d entries left", n)
+	}
+}
+
+func TestScope(t *testing.T) {
+	a := transport.NewContext(context.Background(), transport.Metadata{micro.Tenant: "a"})
+	b := transport.NewContext(context.Background(), transport.Metadata{micro.Tenant: "b"})
+	if Scope(a, "Order.create", "k1") == Scope(b, "Order.create", "k1") {
+		t.Fatal("idempotency key not scoped by tenant")
+	}
+	if Scope(a, "Order.create", "x/k1") == Scope(transport.NewContext(context.Background(),
+		transport.Metadata{micro.Tenant: "a/x"}), "Order.create", "k1") {
+		t.Fatal("scope parts not escaped")
+	}
+}
@@ -0,0 +1,88 @@
+// Package idempotency provides request deduplication by idempotency key
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/lolizeppelin/micro"
+	exc "github.com/lolizeppelin/micro/errors"
+	"github.com/lolizeppelin/micro/transport"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultTTL = 24 * time.Hour
+)
+
+// Result 请求执行结果, 重复请求直接返回
+type Result struct {
+	Body  []byte     `json:"body,omitempty"`
+	Error *exc.Error `json:"error,omitempty"`
+}
+
+/*
+NewResult 根据执行结果生成可重放结果
+1. 成功与业务错误(4xx)保存并重放
+2. 超时/限流/服务端错误返回nil, 释放key允许重试再次执行
+*/
+func NewResult(body []byte, err error) *Result {
+	if err == nil {
+		return &Result{Body: body}
+	}
+	e, ok := exc.As(err)
+	if !ok || e.Code < 400 || e.Code >= 500 ||
+		e.Code == http.StatusRequestTimeout || e.Code == http.StatusTooManyRequests {
+		return nil
+	}
+	return &Result{Error: e}
+}
+
+// Idempotent http方法本身是否幂等(RFC 9110), 幂等方法不需要去重, 未知方法视为非幂等
+func Idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+/*
+Scope 幂等key限定在调用方范围内
+1. 由 endpoint/Tenant头/From-Service头/token主体/幂等key 组成, 各段转义
+2. 不同租户或调用方使用相同幂等key时互不影响, 无法读取对方的结果
+*/
+func Scope(ctx context.Context, endpoint, key string) string {
+	tenant, _ := transport.ContextGet(ctx, micro.Tenant)
+	caller, _ := transport.ContextGet(ctx, transport.Prefix+"From-Service")
+	subject, _ := transport.ContextGet(ctx, micro.TokenSubject)
+	parts := []string{endpoint, tenant, caller, subject, key}
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+func (r *Result) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+func Unmarshal(data []byte) (*Result, error) {
+	result := new(Result)
+	if err := json.Unmarshal(data, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+/*
+Store 幂等结果存储
+1. Acquire 占用key, first为true表示首次执行; 否则等待首个请求完成并返回其结果
+2. Complete 保存执行结果, result为nil时释放key
+*/
+type Store interface {
+	Acquire(ctx context.Context, key string, ttl time.Duration) (result *Result, first bool, err error)
+	Complete(ctx context.Context, key string, result *Result, ttl time.Duration) error
+}
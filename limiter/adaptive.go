@@ -0,0 +1,122 @@
+// Package limiter provides adaptive concurrency limiting
+package limiter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Release 归还并发令牌, rtt为请求耗时, dropped表示请求超时或被下游拒绝
+type Release func(rtt time.Duration, dropped bool)
+
+/*
+Adaptive 基于延迟梯度的自适应并发限制(Gradient2)
+1. 长期延迟为请求延迟的指数加权平均, 短期延迟为单次请求延迟
+2. gradient = clamp(Tolerance * 长期延迟 / 短期延迟, 0.5, 1)
+3. 新上限 = 上限 * gradient + sqrt(上限), 经Smoothing平滑后限制在[MinLimit, MaxLimit]
+4. 请求被丢弃时上限按Backoff缩减, 并发未达上限一半时不提升上限
+*/
+type Adaptive struct {
+	opts Options
+
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	longRTT  float64   // 长期延迟(纳秒)
+	last     time.Time // 最后一次获取令牌时间
+}
+
+func NewAdaptive(opts ...Option) *Adaptive {
+	options := NewOptions(opts...)
+	return &Adaptive{
+		opts:  options,
+		limit: float64(options.InitialLimit),
+		last:  time.Now(),
+	}
+}
+
+// Limit 当前并发上限
+func (a *Adaptive) Limit() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return int(a.limit)
+}
+
+// Inflight 当前并发数
+func (a *Adaptive) Inflight() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.inflight
+}
+
+// RetryAfter 拒绝请求时建议的重试间隔
+func (a *Adaptive) RetryAfter() time.Duration {
+	return a.opts.RetryAfter
+}
+
+// idle 无并发且超过d未使用
+func (a *Adaptive) idle(now time.Time, d time.Duration) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.inflight == 0 && now.Sub(a.last) > d
+}
+
+// Acquire 获取并发令牌, 超过上限时返回false
+func (a *Adaptive) Acquire() (Release, bool) {
+	a.mu.Lock()
+	if a.inflight >= int(a.limit) {
+		a.mu.Unlock()
+		return nil, false
+	}
+	a.inflight++
+	a.last = time.Now()
+	a.mu.Unlock()
+
+	var once sync.Once
+	return func(rtt time.Duration, dropped bool) {
+		once.Do(func() {
+			a.release(rtt, dropped)
+		})
+	}, true
+}
+
+func (a *Adaptive) release(rtt time.Duration, dropped bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	inflight := a.inflight
+	a.inflight--
+
+	if dropped {
+		a.setLimit(a.limit * a.opts.Backoff)
+		return
+	}
+	if rtt <= 0 {
+		return
+	}
+
+	short := float64(rtt)
+	if a.longRTT == 0 {
+		a.longRTT = short
+	} else {
+		a.longRTT = a.longRTT*0.95 + short*0.05
+	}
+	// 长期延迟明显高于当前延迟时快速回落, 避免负载下降后恢复过慢
+	if a.longRTT/short > 2 {
+		a.longRTT = a.longRTT * 0.95
+	}
+
+	// 并发远低于上限, 延迟样本不代表上限处的表现
+	if float64(inflight) < a.limit/2 {
+		return
+	}
+
+	gradient := math.Max(0.5, math.Min(1.0, a.opts.Tolerance*a.longRTT/short))
+	limit := a.limit*gradient + math.Sqrt(a.limit)
+	a.setLimit(a.limit*(1-a.opts.Smoothing) + limit*a.opts.Smoothing)
+}
+
+func (a *Adaptive) setLimit(limit float64) {
+	a.limit = math.Max(float64(a.opts.MinLimit), math.Min(float64(a.opts.MaxLimit), limit))
+}
@@ -0,0 +1,82 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptive(t *testing.T) {
+	l := NewAdaptive(WithLimits(10, 2, 100))
+
+	var releases []Release
+	for i := 0; i < 10; i++ {
+		release, ok := l.Acquire()
+		if !ok {
+			t.Fatalf("acquire %d rejected", i)
+		}
+		releases = append(releases, release)
+	}
+	if _, ok := l.Acquire(); ok {
+		t.Fatalf("acquire over limit")
+	}
+
+	// 延迟稳定, 上限增长
+	for _, release := range releases {
+		release(10*time.Millisecond, false)
+	}
+	for i := 0; i < 20; i++ {
+		var batch []Release
+		for j := 0; j < l.Limit(); j++ {
+			release, _ := l.Acquire()
+			batch = append(batch, release)
+		}
+		for _, release := range batch {
+			release(10*time.Millisecond, false)
+		}
+	}
+	grown := l.Limit()
+	if grown <= 10 {
+		t.Fatalf("limit not grown: %d", grown)
+	}
+
+	// 延迟升高, 上限下降
+	var batch []Release
+	for j := 0; j < grown; j++ {
+		release, _ := l.Acquire()
+		batch = append(batch, release)
+	}
+	for _, release := range batch[:5] {
+		release(100*time.Millisecond, false)
+	}
+	if l.Limit() >= grown {
+		t.Fatalf("limit not reduced: %d >= %d", l.Limit(), grown)
+	}
+	for _, release := range batch[5:] {
+		release(100*time.Millisecond, false)
+	}
+
+	// 丢弃缩减
+	before := l.Limit()
+	release, _ := l.Acquire()
+	release(0, true)
+	if l.Limit() >= before || l.Inflight() != 0 {
+		t.Fatalf("dropped request not backoff: %d inflight %d", l.Limit(), l.Inflight())
+	}
+}
+
+func TestGroupIdle(t *testing.T) {
+	g := NewGroup("test", WithIdleTimeout(20*time.Millisecond))
+	release, _ := g.Get("10.0.0.1:8080").Acquire()
+	g.Get("10.0.0.2:8080")
+
+	// 有并发的限制器不清理
+	time.Sleep(30 * time.Millisecond)
+	g.Get("10.0.0.3:8080")
+	if _, ok := g.limiters["10.0.0.2:8080"]; ok {
+		t.Fatal("idle limiter not evicted")
+	}
+	if _, ok := g.limiters["10.0.0.1:8080"]; !ok {
+		t.Fatal("inflight limiter evicted")
+	}
+	release(time.Millisecond, false)
+}
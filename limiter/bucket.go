@@ -0,0 +1,83 @@
+package limiter
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Store 令牌桶状态存储, 返回0表示获取令牌成功, 否则为需要等待的时间
+type Store interface {
+	Take(ctx context.Context, key string, rate float64, burst int) (time.Duration, error)
+}
+
+// take 按流逝时间补充令牌并尝试取出一个
+func take(tokens float64, last, now time.Time, rate float64, burst int) (float64, time.Duration) {
+	if !last.IsZero() && now.After(last) {
+		tokens += now.Sub(last).Seconds() * rate
+	}
+	tokens = math.Min(tokens, float64(burst))
+	if tokens >= 1 {
+		return tokens - 1, 0
+	}
+	return tokens, time.Duration((1 - tokens) / rate * float64(time.Second))
+}
+
+// full 令牌补满所需时间, 超过该时间未使用的桶与新建桶等价
+func full(tokens, rate float64, burst int) time.Duration {
+	return time.Duration((float64(burst) - tokens) / rate * float64(time.Second))
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time // 令牌补满时间
+}
+
+/*
+localStore 进程内令牌桶
+1. 每SweepInterval清理一次已补满的桶, 避免按租户/调用方等维度生成的key无限增长
+*/
+type localStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+// NewLocalStore 进程内令牌桶
+func NewLocalStore() Store {
+	return &localStore{buckets: map[string]*bucket{}, swept: time.Now()}
+}
+
+// SweepInterval 进程内令牌桶清理间隔
+var SweepInterval = time.Minute
+
+func (s *localStore) sweep(now time.Time) {
+	if now.Sub(s.swept) < SweepInterval {
+		return
+	}
+	s.swept = now
+	for key, b := range s.buckets {
+		if now.After(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func (s *localStore) Take(_ context.Context, key string, rate float64, burst int) (time.Duration, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst)}
+		s.buckets[key] = b
+	}
+	var wait time.Duration
+	b.tokens, wait = take(b.tokens, b.last, now, rate, burst)
+	b.last = now
+	b.full = now.Add(full(b.tokens, rate, burst))
+	return wait, nil
+}
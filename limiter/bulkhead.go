@@ -0,0 +1,119 @@
+package limiter
+
+import (
+	"context"
+	"github.com/lolizeppelin/micro/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+Bulkhead 舱壁隔离, 限制最大并发并提供有界等待队列
+1. 并发未满时直接执行
+2. 并发已满时进入等待队列, 队列已满立即拒绝
+*/
+type Bulkhead struct {
+	name    string
+	slots   chan struct{}
+	queue   int64
+	waiting int64
+	wait    metric.Float64Histogram
+}
+
+func NewBulkhead(name string, concurrency, queue int) *Bulkhead {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if queue < 0 {
+		queue = 0
+	}
+	return &Bulkhead{
+		name:  name,
+		slots: make(chan struct{}, concurrency),
+		queue: int64(queue),
+	}
+}
+
+// Waiting 当前排队数
+func (b *Bulkhead) Waiting() int {
+	return int(atomic.LoadInt64(&b.waiting))
+}
+
+// Acquire 获取执行槽位, 返回false表示队列已满或ctx结束
+func (b *Bulkhead) Acquire(ctx context.Context) (func(), bool) {
+	release := func() { <-b.slots }
+	select {
+	case b.slots <- struct{}{}:
+		return release, true
+	default:
+	}
+	if atomic.AddInt64(&b.waiting, 1) > b.queue {
+		atomic.AddInt64(&b.waiting, -1)
+		return nil, false
+	}
+	defer atomic.AddInt64(&b.waiting, -1)
+
+	start := time.Now()
+	select {
+	case b.slots <- struct{}{}:
+		if b.wait != nil {
+			b.wait.Record(ctx, time.Since(start).Seconds(),
+				metric.WithAttributes(attribute.String("bulkhead", b.name)))
+		}
+		return release, true
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+// Bulkheads 按组件/endpoint名称配置的舱壁, 排队数与等待时间作为指标导出
+type Bulkheads struct {
+	mu        sync.RWMutex
+	bulkheads map[string]*Bulkhead
+	wait      metric.Float64Histogram
+}
+
+func NewBulkheads() *Bulkheads {
+	b := &Bulkheads{
+		bulkheads: map[string]*Bulkhead{},
+	}
+	meter := tracing.GetMeter(LimiterScope, _version)
+	b.wait, _ = meter.Float64Histogram("limiter.bulkhead.wait",
+		metric.WithDescription("bulkhead queue wait time"), metric.WithUnit("s"))
+	depth, _ := meter.Int64ObservableGauge("limiter.bulkhead.queue",
+		metric.WithDescription("current bulkhead queue depth"))
+	if depth != nil {
+		_, _ = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+			b.mu.RLock()
+			defer b.mu.RUnlock()
+			for name, bulkhead := range b.bulkheads {
+				o.ObserveInt64(depth, int64(bulkhead.Waiting()),
+					metric.WithAttributes(attribute.String("bulkhead", name)))
+			}
+			return nil
+		}, depth)
+	}
+	return b
+}
+
+// Add 添加舱壁, name为组件名(e.g User)或endpoint(e.g User.get)
+func (b *Bulkheads) Add(name string, concurrency, queue int) {
+	bulkhead := NewBulkhead(name, concurrency, queue)
+	bulkhead.wait = b.wait
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bulkheads[name] = bulkhead
+}
+
+// Get 获取舱壁, endpoint配置优先于组件配置, 未配置返回nil
+func (b *Bulkheads) Get(component, endpoint string) *Bulkhead {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if bulkhead, ok := b.bulkheads[endpoint]; ok {
+		return bulkhead
+	}
+	return b.bulkheads[component]
+}
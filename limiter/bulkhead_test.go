@@ -0,0 +1,41 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBulkhead(t *testing.T) {
+	b := NewBulkhead("test", 1, 1)
+	release, ok := b.Acquire(context.Background())
+	if !ok {
+		t.Fatal("first acquire rejected")
+	}
+
+	acquired := make(chan func())
+	go func() {
+		r, _ := b.Acquire(context.Background())
+		acquired <- r
+	}()
+	for b.Waiting() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	// 队列已满立即拒绝
+	if _, ok = b.Acquire(context.Background()); ok {
+		t.Fatal("acquire over queue not rejected")
+	}
+
+	release()
+	r := <-acquired
+	if r == nil || b.Waiting() != 0 {
+		t.Fatal("queued request not executed")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, ok = b.Acquire(ctx); ok || ctx.Err() == nil {
+		t.Fatal("queued request should stop with context")
+	}
+	r()
+}
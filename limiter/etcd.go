@@ -0,0 +1,155 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultRatePrefix = "/micro/ratelimit/"
+
+	maxCASRetries = 5
+
+	// bucketSlack 令牌桶租约在补满时间之外的余量
+	bucketSlack = 5 * time.Second
+)
+
+/*
+etcdStore 基于etcd的分布式令牌桶
+1. 令牌数与上次补充时间以"tokens:unixnano"保存在prefix+key
+2. 通过ModRevision比较实现CAS更新, 冲突时重试
+3. key绑定租约, 租约时长为burst/rate加余量, 过期删除的桶与补满的桶等价
+4. 相同时长的租约以双倍时长申请并在时长内复用, 保证写入的key至少保留该时长
+*/
+type etcdStore struct {
+	prefix string
+	kv     clientv3.KV
+	lease  clientv3.Lease
+
+	mu     sync.Mutex
+	leases map[int64]*bucketLease // ttl(秒) -> 租约
+}
+
+type bucketLease struct {
+	id      clientv3.LeaseID
+	expires time.Time // 停止复用时间
+}
+
+// NewEtcdStore 分布式令牌桶, 多个节点共享同一计数
+func NewEtcdStore(client *clientv3.Client, prefix ...string) Store {
+	p := DefaultRatePrefix
+	if len(prefix) > 0 && prefix[0] != "" {
+		p = prefix[0]
+	}
+	if !strings.HasSuffix(p, "/") {
+		p = p + "/"
+	}
+	return &etcdStore{
+		prefix: p,
+		kv:     clientv3.NewKV(client),
+		lease:  clientv3.NewLease(client),
+		leases: map[int64]*bucketLease{},
+	}
+}
+
+// grant 获取可复用的租约
+func (s *etcdStore) grant(ctx context.Context, ttl int64) (clientv3.LeaseID, error) {
+	now := time.Now()
+	s.mu.Lock()
+	l, ok := s.leases[ttl]
+	s.mu.Unlock()
+	if ok && now.Before(l.expires) {
+		return l.id, nil
+	}
+	resp, err := s.lease.Grant(ctx, 2*ttl)
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	s.leases[ttl] = &bucketLease{id: resp.ID, expires: now.Add(time.Duration(ttl) * time.Second)}
+	s.mu.Unlock()
+	return resp.ID, nil
+}
+
+// revoked 租约已失效, 不再复用
+func (s *etcdStore) revoked(ttl int64, id clientv3.LeaseID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l, ok := s.leases[ttl]; ok && l.id == id {
+		delete(s.leases, ttl)
+	}
+}
+
+func (s *etcdStore) Take(ctx context.Context, key string, rate float64, burst int) (time.Duration, error) {
+	key = s.prefix + key
+	ttl := int64(math.Ceil((full(0, rate, burst) + bucketSlack).Seconds()))
+	for i := 0; i < maxCASRetries; i++ {
+		resp, err := s.kv.Get(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		tokens := float64(burst)
+		var last time.Time
+		var rev int64
+		if len(resp.Kvs) > 0 {
+			rev = resp.Kvs[0].ModRevision
+			tokens, last, err = decodeBucket(string(resp.Kvs[0].Value))
+			if err != nil {
+				return 0, err
+			}
+		}
+		now := time.Now()
+		var wait time.Duration
+		tokens, wait = take(tokens, last, now, rate, burst)
+		if wait > 0 { // 令牌不足无需写回
+			return wait, nil
+		}
+		lease, err := s.grant(ctx, ttl)
+		if err != nil {
+			return 0, err
+		}
+		txn, err := s.kv.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(key), "=", rev)).
+			Then(clientv3.OpPut(key, encodeBucket(tokens, now), clientv3.WithLease(lease))).
+			Commit()
+		if errors.Is(err, rpctypes.ErrLeaseNotFound) { // 租约已失效, 重新申请
+			s.revoked(ttl, lease)
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if txn.Succeeded {
+			return 0, nil
+		}
+	}
+	return 0, fmt.Errorf("rate limit bucket %s update conflict", key)
+}
+
+func encodeBucket(tokens float64, last time.Time) string {
+	return strconv.FormatFloat(tokens, 'f', -1, 64) + ":" + strconv.FormatInt(last.UnixNano(), 10)
+}
+
+func decodeBucket(value string) (float64, time.Time, error) {
+	t, n, ok := strings.Cut(value, ":")
+	if !ok {
+		return 0, time.Time{}, fmt.Errorf("rate limit bucket value error")
+	}
+	tokens, err := strconv.ParseFloat(t, 64)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	nano, err := strconv.ParseInt(n, 10, 64)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return tokens, time.Unix(0, nano), nil
+}
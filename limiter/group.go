@@ -0,0 +1,99 @@
+package limiter
+
+import (
+	"context"
+	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	LimiterScope = "micro/limiter"
+)
+
+var (
+	_version, _ = micro.NewVersion("1.0.0")
+)
+
+/*
+Group 按key(endpoint/目标地址)分组的自适应并发限制, 当前上限与并发数作为指标导出
+1. 无并发且超过IdleTimeout未使用的限制器(如已下线节点)在Get时清理, 清理间隔不小于IdleTimeout
+*/
+type Group struct {
+	name string
+	opts []Option
+	idle time.Duration
+
+	mu       sync.RWMutex
+	limiters map[string]*Adaptive
+	swept    atomic.Int64 // 上次清理时间(纳秒)
+}
+
+// NewGroup 创建限制组, name用于区分指标来源 e.g server/client
+func NewGroup(name string, opts ...Option) *Group {
+	g := &Group{
+		name:     name,
+		opts:     opts,
+		idle:     NewOptions(opts...).IdleTimeout,
+		limiters: map[string]*Adaptive{},
+	}
+	g.swept.Store(time.Now().UnixNano())
+
+	meter := tracing.GetMeter(LimiterScope, _version)
+	limit, _ := meter.Int64ObservableGauge("limiter.concurrency.limit",
+		metric.WithDescription("current adaptive concurrency limit"))
+	inflight, _ := meter.Int64ObservableGauge("limiter.concurrency.inflight",
+		metric.WithDescription("current inflight requests"))
+	if limit != nil && inflight != nil {
+		_, _ = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+			g.mu.RLock()
+			defer g.mu.RUnlock()
+			for key, l := range g.limiters {
+				attrs := metric.WithAttributes(attribute.String("group", g.name), attribute.String("key", key))
+				o.ObserveInt64(limit, int64(l.Limit()), attrs)
+				o.ObserveInt64(inflight, int64(l.Inflight()), attrs)
+			}
+			return nil
+		}, limit, inflight)
+	}
+	return g
+}
+
+// Get 获取key对应的限制器, 不存在时创建
+func (g *Group) Get(key string) *Adaptive {
+	g.sweep()
+	g.mu.RLock()
+	l, ok := g.limiters[key]
+	g.mu.RUnlock()
+	if ok {
+		return l
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if l, ok = g.limiters[key]; ok {
+		return l
+	}
+	l = NewAdaptive(g.opts...)
+	g.limiters[key] = l
+	return l
+}
+
+// sweep 清理空闲限制器
+func (g *Group) sweep() {
+	now := time.Now()
+	swept := g.swept.Load()
+	if now.Sub(time.Unix(0, swept)) < g.idle || !g.swept.CompareAndSwap(swept, now.UnixNano()) {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for key, l := range g.limiters {
+		if l.idle(now, g.idle) {
+			delete(g.limiters, key)
+		}
+	}
+}
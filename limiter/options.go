@@ -0,0 +1,85 @@
+package limiter
+
+import "time"
+
+type Options struct {
+	InitialLimit int           // 初始并发上限
+	MinLimit     int           // 并发下限
+	MaxLimit     int           // 并发上限
+	Smoothing    float64       // 上限平滑系数(0,1]
+	Tolerance    float64       // 允许的延迟增长倍数, 短期延迟低于长期延迟*Tolerance时不降低上限
+	Backoff      float64       // 请求被丢弃(超时/过载)时上限缩减比例
+	RetryAfter   time.Duration // 拒绝请求时建议的重试间隔
+	IdleTimeout  time.Duration // Group中无并发且超过该时间未使用的限制器被清理
+}
+
+type Option func(*Options)
+
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		InitialLimit: 20,
+		MinLimit:     4,
+		MaxLimit:     1000,
+		Smoothing:    0.2,
+		Tolerance:    1.5,
+		Backoff:      0.9,
+		RetryAfter:   time.Second,
+		IdleTimeout:  10 * time.Minute,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	if options.MinLimit < 1 {
+		options.MinLimit = 1
+	}
+	if options.MaxLimit < options.MinLimit {
+		options.MaxLimit = options.MinLimit
+	}
+	if options.InitialLimit < options.MinLimit || options.InitialLimit > options.MaxLimit {
+		options.InitialLimit = options.MinLimit
+	}
+	return options
+}
+
+// WithLimits sets the initial, minimum and maximum concurrency limit.
+func WithLimits(initial, min, max int) Option {
+	return func(o *Options) {
+		o.InitialLimit = initial
+		o.MinLimit = min
+		o.MaxLimit = max
+	}
+}
+
+// WithSmoothing sets the smoothing factor applied to limit updates.
+func WithSmoothing(smoothing float64) Option {
+	return func(o *Options) {
+		if smoothing > 0 && smoothing <= 1 {
+			o.Smoothing = smoothing
+		}
+	}
+}
+
+// WithTolerance sets how much latency growth is tolerated before the limit shrinks.
+func WithTolerance(tolerance float64) Option {
+	return func(o *Options) {
+		if tolerance >= 1 {
+			o.Tolerance = tolerance
+		}
+	}
+}
+
+// WithRetryAfter sets the retry hint returned with rejected requests.
+func WithRetryAfter(d time.Duration) Option {
+	return func(o *Options) {
+		o.RetryAfter = d
+	}
+}
+
+// WithIdleTimeout sets how long an unused limiter is kept in a Group.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		if d > 0 {
+			o.IdleTimeout = d
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package limiter
+
+import (
+	"context"
+	"github.com/lolizeppelin/micro"
+	exc "github.com/lolizeppelin/micro/errors"
+	"github.com/lolizeppelin/micro/log"
+	"github.com/lolizeppelin/micro/tracing"
+	"github.com/lolizeppelin/micro/transport"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"sync"
+)
+
+/*
+RateLimit 按endpoint令牌桶限流
+1. 规则优先取配置(endpoint或"*"), 其次取endpoint元数据中的声明(组件RateLimits)
+2. 按规则key取 endpoint/Tenant头/From-Service头/token主体 组成桶key
+3. distributed规则使用分布式存储, 未配置分布式存储时退化为本地计数
+4. 存储异常时放行请求, 限流不影响可用性
+*/
+type RateLimit struct {
+	rules       map[string]*Rule
+	local       Store
+	distributed Store
+	rejected    metric.Int64Counter
+
+	mu     sync.RWMutex
+	parsed map[string]*Rule // endpoint元数据解析缓存
+}
+
+// NewRateLimit rules为配置的endpoint限流规则, "*"匹配所有endpoint; distributed可为nil
+func NewRateLimit(distributed Store, rules map[string]*Rule) *RateLimit {
+	r := &RateLimit{
+		rules:       rules,
+		local:       NewLocalStore(),
+		distributed: distributed,
+		parsed:      map[string]*Rule{},
+	}
+	meter := tracing.GetMeter(LimiterScope, _version)
+	r.rejected, _ = meter.Int64Counter("limiter.rate.rejected",
+		metric.WithDescription("requests rejected by rate limit"))
+	return r
+}
+
+func (r *RateLimit) rule(endpoint string, metadata map[string]string) *Rule {
+	if rule, ok := r.rules[endpoint]; ok {
+		return rule
+	}
+	if rule, ok := r.rules["*"]; ok {
+		return rule
+	}
+	spec := metadata[MetadataRateLimit]
+	if spec == "" {
+		return nil
+	}
+	r.mu.RLock()
+	rule, ok := r.parsed[endpoint]
+	r.mu.RUnlock()
+	if ok {
+		return rule
+	}
+	rule, err := ParseRule(spec)
+	if err != nil {
+		log.Errorf(context.Background(), "endpoint %s rate limit '%s' error: %v", endpoint, spec, err)
+	}
+	r.mu.Lock()
+	r.parsed[endpoint] = rule
+	r.mu.Unlock()
+	return rule
+}
+
+func bucketKey(ctx context.Context, endpoint string, rule *Rule) string {
+	var header string
+	switch rule.Key {
+	case KeyTenant:
+		header = micro.Tenant
+	case KeyCaller:
+		header = transport.Prefix + "From-Service"
+	case KeySubject:
+		header = micro.TokenSubject
+	default:
+		return endpoint
+	}
+	value, _ := transport.ContextGet(ctx, header)
+	return endpoint + "/" + rule.Key + "/" + value
+}
+
+// Allow 获取endpoint令牌, 超出限制时返回429错误(detail附带retry-after)
+func (r *RateLimit) Allow(ctx context.Context, endpoint string, metadata map[string]string) error {
+	rule := r.rule(endpoint, metadata)
+	if rule == nil {
+		return nil
+	}
+	store := r.local
+	if rule.Distributed && r.distributed != nil {
+		store = r.distributed
+	}
+	key := bucketKey(ctx, endpoint, rule)
+	wait, err := store.Take(ctx, key, rule.Rate, rule.Burst)
+	if err != nil {
+		log.Warnf(ctx, "rate limit bucket %s take failed: %v", key, err)
+		return nil
+	}
+	if wait <= 0 {
+		return nil
+	}
+	if r.rejected != nil {
+		r.rejected.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("endpoint", endpoint), attribute.String("key", rule.Key)))
+	}
+	return exc.TooManyRequests("go.micro.server", wait, "rate limit exceeded for %s", key)
+}
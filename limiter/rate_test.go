@@ -0,0 +1,87 @@
+package limiter
+
+import (
+	"context"
+	"github.com/lolizeppelin/micro"
+	exc "github.com/lolizeppelin/micro/errors"
+	"github.com/lolizeppelin/micro/transport"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRule(t *testing.T) {
+	rule, err := ParseRule("rate=0.5, key=tenant, distributed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.Rate != 0.5 || rule.Burst != 1 || rule.Key != KeyTenant || !rule.Distributed {
+		t.Fatalf("rule parse error: %s", rule)
+	}
+	if rule.String() != "rate=0.5,burst=1,key=tenant,distributed" {
+		t.Fatalf("rule string error: %s", rule)
+	}
+	for _, spec := range []string{"", "burst=1", "rate=1,key=user", "rate=x", "rate=1,limit=2"} {
+		if _, err = ParseRule(spec); err == nil {
+			t.Fatalf("spec %q should be rejected", spec)
+		}
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	r := NewRateLimit(nil, map[string]*Rule{
+		"User.get": {Rate: 1, Burst: 2, Key: KeyTenant},
+	})
+	metadata := map[string]string{MetadataRateLimit: "rate=1,burst=1"}
+
+	a := transport.NewContext(context.Background(), transport.Metadata{micro.Tenant: "a"})
+	b := transport.NewContext(context.Background(), transport.Metadata{micro.Tenant: "b"})
+	for i := 0; i < 2; i++ {
+		if err := r.Allow(a, "User.get", metadata); err != nil {
+			t.Fatalf("request %d rejected: %v", i, err)
+		}
+	}
+	err := r.Allow(a, "User.get", metadata)
+	if e := exc.FromError(err); e == nil || e.Code != http.StatusTooManyRequests {
+		t.Fatalf("request over burst not rejected: %v", err)
+	}
+	if _, ok := exc.RetryAfter(err); !ok {
+		t.Fatalf("retry after missing: %v", err)
+	}
+	// 其他租户独立计数
+	if err = r.Allow(b, "User.get", metadata); err != nil {
+		t.Fatalf("tenant b rejected: %v", err)
+	}
+
+	// 未配置的endpoint使用元数据声明
+	if err = r.Allow(a, "User.list", metadata); err != nil {
+		t.Fatalf("first request rejected: %v", err)
+	}
+	if err = r.Allow(b, "User.list", metadata); err == nil {
+		t.Fatalf("endpoint key should share bucket")
+	}
+	if err = r.Allow(a, "User.create", nil); err != nil {
+		t.Fatalf("endpoint without rule rejected: %v", err)
+	}
+}
+
+func TestLocalStoreSweep(t *testing.T) {
+	interval := SweepInterval
+	SweepInterval = 0
+	defer func() { SweepInterval = interval }()
+
+	s := NewLocalStore().(*localStore)
+	ctx := context.Background()
+	_, _ = s.Take(ctx, "tenant-a", 1000, 2)
+	_, _ = s.Take(ctx, "tenant-b", 0.001, 2)
+
+	// tenant-a 已补满被清理, tenant-b 仍在补充
+	time.Sleep(5 * time.Millisecond)
+	_, _ = s.Take(ctx, "tenant-c", 1000, 2)
+	if _, ok := s.buckets["tenant-a"]; ok {
+		t.Fatal("full bucket not evicted")
+	}
+	if _, ok := s.buckets["tenant-b"]; !ok {
+		t.Fatal("refilling bucket evicted")
+	}
+}
@@ -0,0 +1,82 @@
+package limiter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	MetadataRateLimit = "ratelimit" // endpoint元数据中的限流声明
+
+	KeyEndpoint = "endpoint" // 按endpoint限流
+	KeyTenant   = "tenant"   // 按Tenant头限流
+	KeyCaller   = "caller"   // 按From-Service头限流
+	KeySubject  = "subject"  // 按token主体限流
+)
+
+/*
+Rule 令牌桶限流规则
+声明格式 rate=100,burst=200,key=tenant,distributed
+1. rate 每秒补充令牌数(必填)
+2. burst 桶容量, 默认为rate向上取整
+3. key 限流维度 endpoint/tenant/caller/subject, 默认endpoint
+4. distributed 使用分布式计数(etcd), 默认本地计数
+*/
+type Rule struct {
+	Rate        float64
+	Burst       int
+	Key         string
+	Distributed bool
+}
+
+func ParseRule(spec string) (*Rule, error) {
+	rule := &Rule{Key: KeyEndpoint}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(part, "=")
+		var err error
+		switch strings.TrimSpace(name) {
+		case "rate":
+			rule.Rate, err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+		case "burst":
+			rule.Burst, err = strconv.Atoi(strings.TrimSpace(value))
+		case "key":
+			rule.Key = strings.TrimSpace(value)
+		case "distributed":
+			rule.Distributed = true
+		default:
+			return nil, fmt.Errorf("unknown rate limit option %q", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("rate limit option %q value error: %w", name, err)
+		}
+	}
+	if rule.Rate <= 0 {
+		return nil, fmt.Errorf("rate limit rate must be positive")
+	}
+	switch rule.Key {
+	case KeyEndpoint, KeyTenant, KeyCaller, KeySubject:
+	default:
+		return nil, fmt.Errorf("unknown rate limit key %q", rule.Key)
+	}
+	if rule.Burst <= 0 {
+		rule.Burst = int(rule.Rate)
+		if float64(rule.Burst) < rule.Rate {
+			rule.Burst++
+		}
+	}
+	return rule, nil
+}
+
+func (r *Rule) String() string {
+	spec := fmt.Sprintf("rate=%s,burst=%d,key=%s",
+		strconv.FormatFloat(r.Rate, 'f', -1, 64), r.Burst, r.Key)
+	if r.Distributed {
+		spec += ",distributed"
+	}
+	return spec
+}
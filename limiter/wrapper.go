@@ -0,0 +1,49 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/client"
+	exc "github.com/lolizeppelin/micro/errors"
+	"github.com/lolizeppelin/micro/transport"
+	"math"
+	"net/http"
+	"time"
+)
+
+// Dropped 请求是否因超时或过载被丢弃, 用于缩减并发上限
+func Dropped(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	e := exc.FromError(err)
+	switch e.Code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	}
+	return false
+}
+
+// NewCallWrapper 按目标节点地址限制客户端并发, 通过client.WrapCall使用
+func NewCallWrapper(opts ...Option) client.CallWrapper {
+	group := NewGroup("client", opts...)
+	return func(fn client.CallFunc) client.CallFunc {
+		return func(ctx context.Context, node *micro.Node, req micro.Request, opts client.CallOptions) (*transport.Message, error) {
+			l := group.Get(node.Address)
+			release, ok := l.Acquire()
+			if !ok {
+				retry := int64(math.Ceil(l.RetryAfter().Seconds()))
+				return nil, exc.ServiceUnavailable("micro.client.limiter",
+					"concurrency limit exceeded for %s; retry-after=%d", node.Address, retry)
+			}
+			start := time.Now()
+			msg, err := fn(ctx, node, req, opts)
+			release(time.Since(start), Dropped(err))
+			return msg, err
+		}
+	}
+}
@@ -31,12 +31,30 @@ type Service struct {
 }
 
 type Node struct {
-	Id       string            `json:"id"`
-	Version  Version           `json:"version"` // 节点版本号
-	Max      *Version          `json:"max"`     // 节点版本兼容上限
-	Min      *Version          `json:"min"`     // 节点版本兼容下限
-	Address  string            `json:"address"`
-	Metadata map[string]string `json:"metadata"`
+	Id            string            `json:"id"`
+	Version       Version           `json:"version"`                 // 节点版本号
+	Max           *Version          `json:"max"`                     // 节点版本兼容上限
+	Min           *Version          `json:"min"`                     // 节点版本兼容下限
+	Compatibility *Constraint       `json:"compatibility,omitempty"` // 节点兼容版本约束(设置后替代Min/Max), 解码时解析
+	Address       string            `json:"address"`
+	Metadata      map[string]string `json:"metadata"`
+}
+
+// Compatible 请求版本是否在节点兼容范围内(Min/Max不比较patch)
+func (n *Node) Compatible(version Version) bool {
+	if n.Compatibility != nil {
+		return n.Compatibility.Check(version)
+	}
+	if n.Max == nil && n.Min == nil { // 无兼容,需完全匹配
+		return version.Compare(n.Version) == 0
+	}
+	if n.Max != nil && version.Compare(*n.Max) > 0 { // 超过最大兼容
+		return false
+	}
+	if n.Min != nil && version.Compare(*n.Min) < 0 { // 低于最小兼容
+		return false
+	}
+	return true
 }
 
 type Endpoint struct {
@@ -45,3 +63,61 @@ type Endpoint struct {
 	PrimaryKey bool              `json:"pk,omitempty"`       // 是否需要主键
 	Internal   bool              `json:"internal,omitempty"` // 是否内部rpc
 }
+
+const (
+	MetadataRegion    = "region"    // 节点所在地域
+	MetadataZone      = "zone"      // 节点所在可用区
+	MetadataHost      = "host"      // 节点所在宿主机
+	MetadataWeight    = "weight"    // 节点权重, 默认DefaultWeight, 0不分配流量
+	MetadataStatus    = "status"    // 节点状态
+	MetadataSchema    = "schema"    // 节点endpoint结构的内容哈希
+	MetadataPriority  = "priority"  // 节点来源优先级(联邦注册中心写入), 数值越小越优先
+	MetadataSignature = "signature" // 节点记录签名, 参考 registry.Keyring
+)
+
+const (
+	DefaultWeight     = 100
+	StatusDraining    = "draining"    // 下线中, 仅在无其他可用节点时分配流量
+	StatusQuarantined = "quarantined" // 隔离, 不分配流量
+)
+
+// AdminMetadata 更新节点元数据的内部rpc
+const AdminMetadata = "Admin.metadata"
+
+// NodeMetadata 节点元数据更新请求/结果, 请求中空值表示删除对应key
+type NodeMetadata struct {
+	Node     string            `json:"node,omitempty"` // 目标节点id, 设置时校验
+	Metadata map[string]string `json:"metadata"`
+}
+
+// Topology 节点拓扑标签, 通过Node.Metadata发布
+type Topology struct {
+	Region string `json:"region,omitempty"`
+	Zone   string `json:"zone,omitempty"`
+	Host   string `json:"host,omitempty"`
+}
+
+// Metadata 写入元数据, 空值不写入
+func (t Topology) Metadata(md map[string]string) {
+	if t.Region != "" {
+		md[MetadataRegion] = t.Region
+	}
+	if t.Zone != "" {
+		md[MetadataZone] = t.Zone
+	}
+	if t.Host != "" {
+		md[MetadataHost] = t.Host
+	}
+}
+
+// NodeTopology 从节点元数据中读取拓扑标签
+func NodeTopology(node *Node) Topology {
+	if node == nil || node.Metadata == nil {
+		return Topology{}
+	}
+	return Topology{
+		Region: node.Metadata[MetadataRegion],
+		Zone:   node.Metadata[MetadataZone],
+		Host:   node.Metadata[MetadataHost],
+	}
+}
@@ -18,24 +18,39 @@ import (
 // Cache is the registry cache interface
 type Cache interface {
 	GetService(service string) ([]*micro.Service, error)
+	// Stale 服务由快照提供时返回快照时长
+	Stale(service string) (time.Duration, bool)
+	// Health 服务watcher状态
+	Health(service string) (Health, bool)
 	Stop()
 }
 
 type cache struct {
 	registry micro.Registry
 	TTL      time.Duration
+	opts     Options
 
 	// registry cache
 	sync.RWMutex
 	cache   map[string][]*micro.Service
 	ttls    map[string]time.Time
 	watched map[string]bool
+	// services loaded from snapshot and snapshot time
+	stale map[string]time.Time
+
+	// snapshot loaded from disk
+	loaded   bool
+	snapshot *snapshot
+	// notify saver to persist cache
+	dirty chan struct{}
 
 	// used to stop the cache
 	exit chan bool
 
 	// indicate whether its running
 	watchedRunning map[string]bool
+	// watcher health of services
+	healths map[string]*Health
 	// status of the registry
 	// used to hold onto the cache
 	// in failure state
@@ -101,6 +116,7 @@ func (c *cache) del(service string) {
 	// otherwise delete entries
 	delete(c.cache, service)
 	delete(c.ttls, service)
+	delete(c.stale, service)
 }
 
 func (c *cache) get(service string) ([]*micro.Service, error) {
@@ -113,6 +129,10 @@ func (c *cache) get(service string) ([]*micro.Service, error) {
 	ttl := c.ttls[service]
 	// make a copy
 	cp := registry.CopyServices(services)
+	// drop snapshot entries older than max stale
+	if saved, ok := c.stale[service]; ok && c.expired(saved) {
+		cp = nil
+	}
 
 	// got services && within ttl so return cache
 	if c.isValid(cp, ttl) {
@@ -137,6 +157,14 @@ func (c *cache) get(service string) ([]*micro.Service, error) {
 				// return the stale cache
 				return cached, nil
 			}
+			// fallback to snapshot on disk
+			c.Lock()
+			cached = c.loadStale(service)
+			c.Unlock()
+			if len(cached) > 0 {
+				c.setStatus(err)
+				return cached, nil
+			}
 			// otherwise return error
 			return nil, err
 		}
@@ -146,6 +174,8 @@ func (c *cache) get(service string) ([]*micro.Service, error) {
 			c.setStatus(nil)
 		}
 
+		services = c.verified(services)
+
 		// cache results
 		c.Lock()
 		c.set(service, registry.CopyServices(services))
@@ -182,6 +212,8 @@ func (c *cache) get(service string) ([]*micro.Service, error) {
 func (c *cache) set(service string, services []*micro.Service) {
 	c.cache[service] = services
 	c.ttls[service] = time.Now().Add(c.TTL)
+	delete(c.stale, service)
+	c.markDirty()
 }
 
 func (c *cache) update(res *micro.Result) {
@@ -189,6 +221,36 @@ func (c *cache) update(res *micro.Result) {
 		return
 	}
 
+	// nodes failed to verify are removed as if deleted
+	if c.opts.Keyring != nil && (res.Action == "create" || res.Action == "update") && len(res.Service.Nodes) > 0 {
+		verified := c.opts.Keyring.Verified([]*micro.Service{res.Service})
+		var valid []*micro.Node
+		if len(verified) > 0 {
+			valid = verified[0].Nodes
+		}
+		if len(valid) < len(res.Service.Nodes) {
+			ids := make(map[string]bool, len(valid))
+			for _, node := range valid {
+				ids[node.Id] = true
+			}
+			var invalid []*micro.Node
+			for _, node := range res.Service.Nodes {
+				if !ids[node.Id] {
+					invalid = append(invalid, node)
+				}
+			}
+			deleted := *res.Service
+			deleted.Nodes = invalid
+			c.update(&micro.Result{Action: "delete", Service: &deleted})
+			if len(valid) == 0 {
+				return
+			}
+			service := *res.Service
+			service.Nodes = valid
+			res = &micro.Result{Action: res.Action, Service: &service}
+		}
+	}
+
 	c.Lock()
 	defer c.Unlock()
 
@@ -318,6 +380,7 @@ func (c *cache) run(service string) {
 	}()
 
 	var a, b int
+	var started bool
 
 	ctx := context.Background()
 
@@ -340,6 +403,7 @@ func (c *cache) run(service string) {
 
 			d := backoff(a)
 			c.setStatus(err)
+			c.watchFailed(service, err)
 
 			if a > 3 {
 				log.Debugf(ctx, "rcache: %s backing off %d", err.Error(), d)
@@ -354,15 +418,21 @@ func (c *cache) run(service string) {
 
 		// reset a
 		a = 0
+		c.watchStarted(service, started)
+		started = true
+
+		// replace snapshot entries once the registry is back
+		c.refresh(service)
 
 		// watch for events
-		if err := c.watch(w); err != nil {
+		if err := c.watch(service, w); err != nil {
 			if c.quit() {
 				return
 			}
 
 			d := backoff(b)
 			c.setStatus(err)
+			c.watchFailed(service, err)
 
 			if b > 3 {
 				log.Debugf(ctx, "rcache: %s backing off %d", err.Error(), d)
@@ -382,7 +452,7 @@ func (c *cache) run(service string) {
 
 // watch loops the next event and calls update
 // it returns if there's an error
-func (c *cache) watch(w micro.Watcher) error {
+func (c *cache) watch(service string, w micro.Watcher) error {
 	// used to stop the watch
 	stop := make(chan bool)
 
@@ -412,6 +482,7 @@ func (c *cache) watch(w micro.Watcher) error {
 			// reset status
 			c.setStatus(nil)
 		}
+		c.watchSucceed(service)
 
 		c.update(res)
 	}
@@ -446,16 +517,31 @@ func (c *cache) Stop() {
 }
 
 // New returns a new cache
-func New(r micro.Registry, ttl time.Duration) Cache {
+func New(r micro.Registry, ttl time.Duration, opts ...Option) Cache {
 	rand.Seed(time.Now().UnixNano())
 
-	return &cache{
+	options := Options{
+		MaxStale: DefaultMaxStale,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	c := &cache{
 		registry:       r,
 		TTL:            ttl,
+		opts:           options,
 		watched:        make(map[string]bool),
 		watchedRunning: make(map[string]bool),
+		healths:        make(map[string]*Health),
 		cache:          make(map[string][]*micro.Service),
 		ttls:           make(map[string]time.Time),
+		stale:          make(map[string]time.Time),
 		exit:           make(chan bool),
 	}
+	if options.Snapshot != "" {
+		c.dirty = make(chan struct{}, 1)
+		go c.saver()
+	}
+	return c
 }
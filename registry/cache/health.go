@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"time"
+)
+
+// Health 服务watcher状态, Restarts持续增长说明watcher不断重建
+type Health struct {
+	Running     bool      // watcher运行中
+	Restarts    int       // watcher重建次数
+	Failures    int       // 连续失败次数, watch成功收到事件后清零
+	LastError   string    // 最近一次错误
+	LastErrorAt time.Time // 最近一次错误时间
+}
+
+func (c *cache) health(service string) *Health {
+	h, ok := c.healths[service]
+	if !ok {
+		h = &Health{}
+		c.healths[service] = h
+	}
+	return h
+}
+
+// watchFailed 记录watcher创建或运行失败
+func (c *cache) watchFailed(service string, err error) {
+	c.Lock()
+	defer c.Unlock()
+	h := c.health(service)
+	h.Running = false
+	h.Failures++
+	h.LastError = err.Error()
+	h.LastErrorAt = time.Now()
+}
+
+// watchStarted 记录watcher创建成功, 首次创建不计入重建次数
+func (c *cache) watchStarted(service string, restart bool) {
+	c.Lock()
+	defer c.Unlock()
+	h := c.health(service)
+	h.Running = true
+	if restart {
+		h.Restarts++
+	}
+}
+
+func (c *cache) watchSucceed(service string) {
+	c.Lock()
+	defer c.Unlock()
+	if h := c.health(service); h.Failures > 0 {
+		h.Failures = 0
+	}
+}
+
+func (c *cache) Health(service string) (Health, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	h, ok := c.healths[service]
+	if !ok {
+		return Health{}, false
+	}
+	return *h, true
+}
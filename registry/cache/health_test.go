@@ -0,0 +1,27 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	c := New(downRegistry{}, time.Minute).(*cache)
+	defer c.Stop()
+	_, _ = c.GetService("lobby")
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		h, ok := c.Health("lobby")
+		if ok && h.Failures > 1 {
+			if h.Running || h.LastError == "" {
+				t.Fatalf("unexpected health %+v", h)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("watch failures not recorded %+v", h)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
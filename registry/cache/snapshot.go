@@ -0,0 +1,219 @@
+package cache
+
+import (
+	"context"
+	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/log"
+	"github.com/lolizeppelin/micro/registry"
+	"github.com/lolizeppelin/micro/utils"
+	"github.com/lolizeppelin/micro/utils/flock"
+	"time"
+)
+
+const (
+	DefaultMaxStale = 24 * time.Hour
+)
+
+type Options struct {
+	Snapshot string            // 快照文件路径, 为空不启用
+	MaxStale time.Duration     // 快照最大过期时长, 超过后丢弃
+	Keyring  *registry.Keyring // 验证注册记录签名, 丢弃未签名或验证失败的节点
+}
+
+type Option func(*Options)
+
+// WithSnapshot persists the cache to a local file, loaded when the registry is unreachable.
+func WithSnapshot(path string) Option {
+	return func(o *Options) {
+		o.Snapshot = path
+	}
+}
+
+// WithMaxStale sets the maximum age of snapshot entries, older entries are dropped.
+func WithMaxStale(d time.Duration) Option {
+	return func(o *Options) {
+		o.MaxStale = d
+	}
+}
+
+// snapshot 缓存快照, 多进程共享同一文件时按服务合并
+type snapshot struct {
+	Services map[string][]*micro.Service `json:"services"`
+	Saved    map[string]time.Time        `json:"saved"` // 各服务快照时间
+}
+
+func (c *cache) lockSnapshot(shared bool) (*flock.Flock, error) {
+	lock := flock.New(c.opts.Snapshot + ".lock")
+	var err error
+	if shared {
+		err = lock.RLock()
+	} else {
+		err = lock.Lock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+func (c *cache) readSnapshot() *snapshot {
+	lock, err := c.lockSnapshot(true)
+	if err != nil {
+		log.Warnf(context.Background(), "rcache: lock snapshot failed: %v", err)
+		return nil
+	}
+	defer lock.Close()
+	s := new(snapshot)
+	if ok, _ := utils.PathIsRegularFile(c.opts.Snapshot); !ok {
+		return nil
+	}
+	if err = utils.LoadJson(c.opts.Snapshot, s); err != nil {
+		log.Warnf(context.Background(), "rcache: load snapshot failed: %v", err)
+		return nil
+	}
+	return s
+}
+
+// loadStale 注册中心不可达时从快照加载服务, 需持有写锁
+func (c *cache) loadStale(service string) []*micro.Service {
+	if c.opts.Snapshot == "" {
+		return nil
+	}
+	if !c.loaded {
+		c.loaded = true
+		c.snapshot = c.readSnapshot()
+	}
+	if c.snapshot == nil {
+		return nil
+	}
+	services := c.verified(c.snapshot.Services[service])
+	saved := c.snapshot.Saved[service]
+	if len(services) == 0 || c.expired(saved) {
+		return nil
+	}
+	log.Warnf(context.Background(), "rcache: registry unreachable, use snapshot of %s aged %s",
+		service, time.Since(saved).Truncate(time.Second))
+	c.cache[service] = registry.CopyServices(services)
+	c.ttls[service] = time.Time{} // 每次请求重新尝试注册中心
+	c.stale[service] = saved
+	return registry.CopyServices(services)
+}
+
+func (c *cache) expired(saved time.Time) bool {
+	return c.opts.MaxStale > 0 && time.Since(saved) > c.opts.MaxStale
+}
+
+// Stale returns the snapshot age of a service served from the snapshot.
+func (c *cache) Stale(service string) (time.Duration, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	saved, ok := c.stale[service]
+	if !ok {
+		return 0, false
+	}
+	return time.Since(saved), true
+}
+
+func (c *cache) markDirty() {
+	if c.dirty == nil {
+		return
+	}
+	select {
+	case c.dirty <- struct{}{}:
+	default:
+	}
+}
+
+// saver 合并写入快照, 1s内多次变更只写一次
+func (c *cache) saver() {
+	for {
+		select {
+		case <-c.exit:
+			return
+		case <-c.dirty:
+		}
+		time.Sleep(time.Second)
+		if err := c.save(); err != nil {
+			log.Warnf(context.Background(), "rcache: save snapshot failed: %v", err)
+		}
+	}
+}
+
+func (c *cache) save() error {
+	now := time.Now()
+	c.RLock()
+	fresh := make(map[string][]*micro.Service, len(c.cache))
+	for service, services := range c.cache {
+		if _, stale := c.stale[service]; stale || len(services) == 0 {
+			continue
+		}
+		fresh[service] = registry.CopyServices(services)
+	}
+	c.RUnlock()
+	if len(fresh) == 0 {
+		return nil
+	}
+
+	lock, err := c.lockSnapshot(false)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	s := &snapshot{
+		Services: map[string][]*micro.Service{},
+		Saved:    map[string]time.Time{},
+	}
+	if ok, _ := utils.PathIsRegularFile(c.opts.Snapshot); ok {
+		_ = utils.LoadJson(c.opts.Snapshot, s)
+	}
+	if s.Services == nil || s.Saved == nil {
+		s.Services = map[string][]*micro.Service{}
+		s.Saved = map[string]time.Time{}
+	}
+	for service, saved := range s.Saved {
+		if c.expired(saved) {
+			delete(s.Services, service)
+			delete(s.Saved, service)
+		}
+	}
+	for service, services := range fresh {
+		s.Services[service] = services
+		s.Saved[service] = now
+	}
+	return utils.SaveJson(c.opts.Snapshot, s)
+}
+
+// refresh watch恢复后使用注册中心数据替换快照数据
+func (c *cache) refresh(service string) {
+	c.RLock()
+	_, stale := c.stale[service]
+	c.RUnlock()
+	if !stale {
+		return
+	}
+	services, err := c.registry.GetService(service)
+	if err != nil {
+		return
+	}
+	c.setStatus(nil)
+	services = c.verified(services)
+	c.Lock()
+	c.set(service, registry.CopyServices(services))
+	c.Unlock()
+}
+
+// verified 丢弃签名验证失败的节点, 写入c.cache的服务都需经过验证
+func (c *cache) verified(services []*micro.Service) []*micro.Service {
+	if c.opts.Keyring == nil {
+		return services
+	}
+	return c.opts.Keyring.Verified(services)
+}
+
+// WithKeyring verifies the signatures of registry records.
+func WithKeyring(k *registry.Keyring) Option {
+	return func(o *Options) {
+		o.Keyring = k
+	}
+}
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/registry"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// downRegistry 不可达的注册中心
+type downRegistry struct {
+	micro.Registry
+}
+
+func (downRegistry) GetService(string) ([]*micro.Service, error) {
+	return nil, errors.New("registry unreachable")
+}
+
+func (downRegistry) Watch(string) (micro.Watcher, error) {
+	return nil, errors.New("registry unreachable")
+}
+
+func TestSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+	r := registry.NewMemoryRegistry()
+	_ = r.Register(context.Background(), &micro.Service{
+		Name:  "lobby",
+		Nodes: []*micro.Node{{Id: "a", Address: "127.0.0.1:1"}},
+	})
+
+	warm := New(r, time.Minute, WithSnapshot(path)).(*cache)
+	defer warm.Stop()
+	if _, err := warm.GetService("lobby"); err != nil {
+		t.Fatal(err)
+	}
+	if err := warm.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	cold := New(downRegistry{}, time.Minute, WithSnapshot(path))
+	defer cold.Stop()
+	services, err := cold.GetService("lobby")
+	if err != nil || len(services) != 1 || services[0].Nodes[0].Id != "a" {
+		t.Fatalf("expect snapshot services, got %v %v", services, err)
+	}
+	if _, stale := cold.Stale("lobby"); !stale {
+		t.Fatal("expect stale services")
+	}
+
+	expired := New(downRegistry{}, time.Minute, WithSnapshot(path), WithMaxStale(time.Nanosecond))
+	defer expired.Stop()
+	if _, err = expired.GetService("lobby"); err == nil {
+		t.Fatal("expect expired snapshot dropped")
+	}
+}
+
+func TestSnapshotVerified(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+	keyring := registry.NewKeyring(&registry.ServiceKeys{Service: "lobby", Keys: []*registry.SigningKey{
+		{ID: "k1", Algorithm: registry.AlgorithmHMAC, Secret: []byte("secret")},
+	}})
+	r := registry.NewMemoryRegistry()
+	_ = r.Register(context.Background(), &micro.Service{
+		Name:  "lobby",
+		Nodes: []*micro.Node{{Id: "unsigned", Address: "127.0.0.1:1"}},
+	})
+
+	warm := New(r, time.Minute, WithSnapshot(path)).(*cache)
+	defer warm.Stop()
+	if _, err := warm.GetService("lobby"); err != nil {
+		t.Fatal(err)
+	}
+	if err := warm.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 快照中未签名的节点不可用
+	cold := New(downRegistry{}, time.Minute, WithSnapshot(path), WithKeyring(keyring))
+	defer cold.Stop()
+	if services, err := cold.GetService("lobby"); err == nil {
+		t.Fatalf("unsigned snapshot node served: %v", services)
+	}
+
+	// 注册中心恢复后刷新快照条目
+	c := New(r, time.Minute, WithKeyring(keyring)).(*cache)
+	defer c.Stop()
+	c.Lock()
+	c.stale["lobby"] = time.Now()
+	c.Unlock()
+	c.refresh("lobby")
+	c.RLock()
+	defer c.RUnlock()
+	if len(c.cache["lobby"]) != 0 {
+		t.Fatalf("unsigned node cached by refresh: %v", c.cache["lobby"])
+	}
+}
@@ -0,0 +1,66 @@
+package registry
+
+import (
+	"fmt"
+	"github.com/lolizeppelin/micro/utils/jsonschema"
+	"sort"
+)
+
+/*
+Incompatible 新版本endpoint结构相对旧版本的不兼容变更
+1. endpoint删除, 主键要求变更, 外部endpoint改为内部
+2. 请求/返回载荷类型(json/bytes)变更
+3. query与请求载荷按请求方向比较, 返回载荷按返回方向比较, 参考 jsonschema.Diff
+*/
+func Incompatible(old, latest *Schemas) []jsonschema.Change {
+	var changes []jsonschema.Change
+	names := make([]string, 0, len(old.Endpoints))
+	for name := range old.Endpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		o := old.Endpoints[name]
+		n, ok := latest.Endpoints[name]
+		if !ok {
+			changes = append(changes, jsonschema.Change{Path: name, Reason: "endpoint removed"})
+			continue
+		}
+		if o.PrimaryKey != n.PrimaryKey {
+			changes = append(changes, jsonschema.Change{Path: name,
+				Reason: fmt.Sprintf("primary key changed from %v to %v", o.PrimaryKey, n.PrimaryKey)})
+		}
+		if !o.Internal && n.Internal {
+			changes = append(changes, jsonschema.Change{Path: name, Reason: "endpoint became internal"})
+		}
+		changes = append(changes, prefixed(name+".query:", jsonschema.Diff(o.Query, n.Query, true))...)
+		changes = append(changes, contentDiff(name+".request:", o.Request, n.Request, true)...)
+		changes = append(changes, contentDiff(name+".response:", o.Response, n.Response, false)...)
+	}
+	return changes
+}
+
+func contentDiff(path string, old, latest *jsonschema.ContentBody, input bool) []jsonschema.Change {
+	if old == nil {
+		// 旧版本无请求载荷时, 新增载荷的必填字段同样不兼容
+		if input && latest != nil {
+			return prefixed(path, jsonschema.Diff(nil, latest.Schema, input))
+		}
+		return nil
+	}
+	if latest == nil {
+		return []jsonschema.Change{{Path: path, Reason: "body removed"}}
+	}
+	if old.Type != latest.Type {
+		return []jsonschema.Change{{Path: path, Reason: fmt.Sprintf("body type changed from %s to %s", old.Type, latest.Type)}}
+	}
+	return prefixed(path, jsonschema.Diff(old.Schema, latest.Schema, input))
+}
+
+func prefixed(prefix string, changes []jsonschema.Change) []jsonschema.Change {
+	for i := range changes {
+		changes[i].Path = prefix + changes[i].Path
+	}
+	return changes
+}
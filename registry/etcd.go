@@ -16,7 +16,6 @@ import (
 	"time"
 
 	hash "github.com/mitchellh/hashstructure/v2"
-	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
 	clientV3 "go.etcd.io/etcd/client/v3"
 )
 
@@ -28,10 +27,14 @@ var (
 type etcdRegistry struct {
 	client  *clientV3.Client
 	options Options
+	root    string // 命名空间根路径
 
 	sync.RWMutex
 	register map[string]uint64
 	leases   map[string]clientV3.LeaseID
+	keepers  map[string]*keeper
+	events   chan *LeaseEvent
+	stats    *watchStats
 }
 
 func NewEtcdRegistry(opts ...Option) (micro.Registry, error) {
@@ -49,8 +52,12 @@ func NewEtcdRegistry(opts ...Option) (micro.Registry, error) {
 
 	e := &etcdRegistry{
 		options:  options,
+		root:     namespacePath(options.Namespace),
 		register: make(map[string]uint64),
 		leases:   make(map[string]clientV3.LeaseID),
+		keepers:  make(map[string]*keeper),
+		events:   make(chan *LeaseEvent, 64),
+		stats:    &watchStats{},
 		client:   options.Client,
 	}
 	return e, nil
@@ -67,145 +74,124 @@ func decode(ds []byte) *micro.Service {
 	return s
 }
 
-func nodePath(s, id string) string {
+// namespacePath 命名空间根路径, 默认命名空间为/micro/registry/
+func namespacePath(namespace string) string {
+	if namespace == "" {
+		return prefix
+	}
+	return path.Join(prefix, strings.Replace(namespace, "/", "-", -1)) + "/"
+}
+
+func nodePath(root, s, id string) string {
 	service := strings.Replace(s, "/", "-", -1)
 	node := strings.Replace(id, "/", "-", -1)
-	return path.Join(prefix, service, node)
+	return path.Join(root, service, node)
+}
+
+func servicePath(root, s string) string {
+	return path.Join(root, strings.Replace(s, "/", "-", -1))
+}
+
+// scoped key是否为root下的节点(root/service/node), 排除其他命名空间的节点
+func scoped(root string, key []byte) bool {
+	rest := strings.TrimPrefix(string(key), root)
+	return strings.Count(rest, "/") == 1
 }
 
-func servicePath(s string) string {
-	return path.Join(prefix, strings.Replace(s, "/", "-", -1))
+// Namespace returns the namespace of the registry.
+func (e *etcdRegistry) Namespace() string {
+	return e.options.Namespace
 }
 
 func (e *etcdRegistry) Client() *clientV3.Client {
 	return e.client
 }
 
+/*
+registerNode 注册单个节点
+1. 节点信息未变更且续租正常时不访问etcd
+2. 节点信息变更时使用当前租约立即写入
+3. 首次注册时授权租约并启动KeepAlive续租流, 租约丢失后由续租流自动重新授权写入
+*/
 func (e *etcdRegistry) registerNode(ctx context.Context, s *micro.Service, node *micro.Node) error {
 	if len(s.Nodes) == 0 {
 		return errors.New("require at least one node")
 	}
 
-	// check existing lease cache
-	e.RLock()
-	leaseID, ok := e.leases[s.Name+node.Id]
-	e.RUnlock()
-
-	if !ok {
-		// missing lease, check if the key exists
-		_ctx, cancel := context.WithTimeout(ctx, e.options.Timeout)
-		defer cancel()
-
-		// look for the existing key
-		rsp, err := e.client.Get(_ctx, nodePath(s.Name, node.Id), clientV3.WithSerializable())
-		if err != nil {
-			return err
-		}
-
-		// get the existing lease
-		for _, kv := range rsp.Kvs {
-			if kv.Lease > 0 {
-				leaseID = clientV3.LeaseID(kv.Lease)
-
-				// decode the existing node
-				srv := decode(kv.Value)
-				if srv == nil || len(srv.Nodes) == 0 {
-					continue
-				}
-
-				// create hash of service; uint64
-				var h uint64
-				h, err = hash.Hash(srv.Nodes[0], hash.FormatV2, nil)
-				if err != nil {
-					continue
-				}
-
-				// save the info
-				e.Lock()
-				e.leases[s.Name+node.Id] = leaseID
-				e.register[s.Name+node.Id] = h
-				e.Unlock()
-
-				break
-			}
-		}
+	service := &micro.Service{
+		Name:      s.Name,
+		Version:   s.Version,
+		Metadata:  s.Metadata,
+		Endpoints: s.Endpoints,
+		Nodes:     []*micro.Node{node},
 	}
-
-	var leaseNotFound bool
-
-	// renew the lease if it exists
-	if leaseID > 0 {
-		log.Debugf(ctx, "Renewing existing lease for %s %d", s.Name, leaseID)
-		if _, err := e.client.KeepAliveOnce(ctx, leaseID); err != nil {
-			if !errors.Is(err, rpctypes.ErrLeaseNotFound) {
-				return err
-			}
-
-			log.Debugf(ctx, "Lease not found for %s %d", s.Name, leaseID)
-			// lease not found do register
-			leaseNotFound = true
+	var err error
+	if e.options.Keyring != nil {
+		if service, err = e.options.Keyring.Sign(service); err != nil {
+			return err
 		}
 	}
 
-	// create hash of service; uint64
-	h, err := hash.Hash(node, hash.FormatV2, nil)
+	// create hash of service; uint64, signature included so key rotation re-registers
+	h, err := hash.Hash(service.Nodes[0], hash.FormatV2, nil)
 	if err != nil {
 		return err
 	}
+	key := s.Name + node.Id
 
-	// get existing hash for the service node
 	e.Lock()
-	v, ok := e.register[s.Name+node.Id]
+	v, registered := e.register[key]
+	k := e.keepers[key]
+	if k != nil {
+		k.service = service
+	}
 	e.Unlock()
 
 	// the service is unchanged, skip registering
-	if ok && v == h && !leaseNotFound {
+	if registered && v == h {
 		log.Debugf(ctx, "Service %s node %s unchanged skipping registration", s.Name, node.Id)
 		return nil
 	}
 
-	service := &micro.Service{
-		Name:      s.Name,
-		Version:   s.Version,
-		Metadata:  s.Metadata,
-		Endpoints: s.Endpoints,
-		Nodes:     []*micro.Node{node},
-	}
-
-	var cancel context.CancelFunc
-	ctx, cancel = context.WithTimeout(ctx, e.options.Timeout)
-	defer cancel()
-
-	var lgr *clientV3.LeaseGrantResponse
-	if e.options.TTL.Seconds() > 0 {
-		// get a lease used to expire keys since we have a ttl
-		lgr, err = e.client.Grant(ctx, int64(e.options.TTL.Seconds()))
-		if err != nil {
+	if k != nil || e.options.TTL.Seconds() <= 0 {
+		// push changes with current lease
+		var lease clientV3.LeaseID
+		if k != nil {
+			e.RLock()
+			lease = k.lease
+			e.RUnlock()
+		}
+		if err = e.put(ctx, service, lease); err != nil {
 			return err
 		}
-		log.Debugf(ctx, "Registering %s id %s with lease %v and leaseID %v and ttl %v",
-			service.Name, node.Id, lgr, lgr.ID, e.options.TTL)
-
-	}
-	// create an entry for the node
-	if lgr != nil {
-		_, err = e.client.Put(ctx, nodePath(service.Name, node.Id), encode(service), clientV3.WithLease(lgr.ID))
-	} else {
-		_, err = e.client.Put(ctx, nodePath(service.Name, node.Id), encode(service))
+		e.Lock()
+		e.register[key] = h
+		e.Unlock()
+		return nil
 	}
+
+	// get a lease used to expire keys since we have a ttl
+	lease, err := e.grant(ctx, service)
 	if err != nil {
 		return err
 	}
+	log.Debugf(ctx, "Registering %s id %s with leaseID %v and ttl %v",
+		service.Name, node.Id, lease, e.options.TTL)
 
+	_ctx, cancel := context.WithCancel(context.Background())
+	k = &keeper{cancel: cancel, lease: lease, service: service}
 	e.Lock()
+	if old := e.keepers[key]; old != nil {
+		old.cancel()
+	}
+	e.keepers[key] = k
 	// save our hash of the service
-	e.register[s.Name+node.Id] = h
+	e.register[key] = h
 	// save our leaseID of the service
-	if lgr != nil {
-		e.leases[s.Name+node.Id] = lgr.ID
-	}
+	e.leases[key] = lease
 	e.Unlock()
 
+	go e.keepalive(_ctx, key, k)
 	return nil
 }
 
@@ -214,11 +200,18 @@ func (e *etcdRegistry) Deregister(ctx context.Context, s *micro.Service) error {
 		return errors.New("require at least one node")
 	}
 	for _, node := range s.Nodes {
+		key := s.Name + node.Id
 		e.Lock()
+		// stop keepalive stream
+		if k := e.keepers[key]; k != nil {
+			k.cancel()
+			delete(e.keepers, key)
+		}
+		lease := e.leases[key]
 		// delete our hash of the service
-		delete(e.register, s.Name+node.Id)
+		delete(e.register, key)
 		// delete our lease of the service
-		delete(e.leases, s.Name+node.Id)
+		delete(e.leases, key)
 		e.Unlock()
 
 		log.Debugf(ctx, "deregister %s id %s", s.Name, node.Id)
@@ -226,10 +219,13 @@ func (e *etcdRegistry) Deregister(ctx context.Context, s *micro.Service) error {
 		f := func() error {
 			_ctx, cancel := context.WithTimeout(ctx, e.options.Timeout)
 			defer cancel()
-			_, err := e.client.Delete(_ctx, nodePath(s.Name, node.Id))
+			_, err := e.client.Delete(_ctx, nodePath(e.root, s.Name, node.Id))
 			if err != nil {
 				return err
 			}
+			if lease > 0 {
+				_, _ = e.client.Revoke(_ctx, lease)
+			}
 			return nil
 		}
 
@@ -261,21 +257,36 @@ func (e *etcdRegistry) Register(ctx context.Context, s *micro.Service) error {
 }
 
 func (e *etcdRegistry) GetService(name string) ([]*micro.Service, error) {
+	for _, namespace := range e.namespaces() {
+		services, err := e.getService(namespacePath(namespace), name)
+		if errors.Is(err, micro.ErrServiceNotFound) {
+			continue
+		}
+		return services, err
+	}
+	return nil, micro.ErrServiceNotFound
+}
+
+// namespaces 当前命名空间与回退命名空间
+func (e *etcdRegistry) namespaces() []string {
+	return append([]string{e.options.Namespace}, e.options.Fallbacks...)
+}
+
+func (e *etcdRegistry) getService(root, name string) ([]*micro.Service, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), e.options.Timeout)
 	defer cancel()
 
-	rsp, err := e.client.Get(ctx, servicePath(name)+"/", clientV3.WithPrefix(), clientV3.WithSerializable())
+	rsp, err := e.client.Get(ctx, servicePath(root, name)+"/", clientV3.WithPrefix(), clientV3.WithSerializable())
 	if err != nil {
 		return nil, err
 	}
 
-	if len(rsp.Kvs) == 0 {
-		return nil, micro.ErrServiceNotFound
-	}
-
 	serviceMap := map[string]*micro.Service{}
 
 	for _, n := range rsp.Kvs {
+		if !scoped(root, n.Key) {
+			continue
+		}
 		if service := decode(n.Value); service != nil {
 			main := utils.UnsafeToString(service.Version)
 			s, ok := serviceMap[main]
@@ -293,25 +304,56 @@ func (e *etcdRegistry) GetService(name string) ([]*micro.Service, error) {
 		}
 	}
 
+	if len(serviceMap) == 0 {
+		return nil, micro.ErrServiceNotFound
+	}
+
 	return maps.Values(serviceMap), nil
 }
 
+// ListServices 列出当前命名空间服务, 回退命名空间中仅补充当前命名空间不存在的服务
 func (e *etcdRegistry) ListServices() ([]*micro.Service, error) {
+	var services []*micro.Service
+	seen := make(map[string]bool)
+	for _, namespace := range e.namespaces() {
+		found, err := e.listServices(namespacePath(namespace))
+		if err != nil {
+			return nil, err
+		}
+		names := make(map[string]bool)
+		for _, service := range found {
+			if seen[service.Name] {
+				continue
+			}
+			names[service.Name] = true
+			services = append(services, service)
+		}
+		for name := range names {
+			seen[name] = true
+		}
+	}
+
+	// sort the services
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+
+	return services, nil
+}
+
+func (e *etcdRegistry) listServices(root string) ([]*micro.Service, error) {
 	versions := make(map[string]*micro.Service)
 
 	ctx, cancel := context.WithTimeout(context.Background(), e.options.Timeout)
 	defer cancel()
 
-	rsp, err := e.client.Get(ctx, prefix, clientV3.WithPrefix(), clientV3.WithSerializable())
+	rsp, err := e.client.Get(ctx, root, clientV3.WithPrefix(), clientV3.WithSerializable())
 	if err != nil {
 		return nil, err
 	}
 
-	if len(rsp.Kvs) == 0 {
-		return []*micro.Service{}, nil
-	}
-
 	for _, n := range rsp.Kvs {
+		if !scoped(root, n.Key) {
+			continue
+		}
 		sn := decode(n.Value)
 		if sn == nil {
 			continue
@@ -330,13 +372,14 @@ func (e *etcdRegistry) ListServices() ([]*micro.Service, error) {
 	for _, service := range versions {
 		services = append(services, service)
 	}
-
-	// sort the services
-	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
-
 	return services, nil
 }
 
+// WatchHealth returns the health of the registry watchers.
+func (e *etcdRegistry) WatchHealth() WatchHealth {
+	return e.stats.get()
+}
+
 func (e *etcdRegistry) Watch(service string) (micro.Watcher, error) {
 	return newEtcdWatcher(e, e.options.Timeout, service)
 }
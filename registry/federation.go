@@ -0,0 +1,233 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/log"
+	"github.com/lolizeppelin/micro/utils"
+	"maps"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Source 联邦注册中心的数据源
+type Source struct {
+	Registry micro.Registry
+	Priority int               // 优先级, 数值越小越优先, 本地集群一般为0
+	Labels   map[string]string // 写入来源节点的元数据 e.g {"cluster": "dc1", "region": "dc1"}
+}
+
+/*
+federatedRegistry 联邦注册中心, 合并多个集群的注册中心
+1. 注册/注销仅作用于最高优先级(本地)数据源
+2. 查询与watch合并全部数据源结果, 节点元数据写入来源标签(不覆盖已有key)与优先级(micro.MetadataPriority)
+3. 数据源不可用时忽略, 由selector.Priority在本地无可用节点时切换到低优先级节点
+*/
+type federatedRegistry struct {
+	sources []*Source
+}
+
+func NewFederatedRegistry(sources ...*Source) (micro.Registry, error) {
+	if len(sources) == 0 {
+		return nil, errors.New("require at least one source")
+	}
+	sources = append([]*Source(nil), sources...)
+	sort.SliceStable(sources, func(i, j int) bool { return sources[i].Priority < sources[j].Priority })
+	return &federatedRegistry{sources: sources}, nil
+}
+
+func (f *federatedRegistry) primary() micro.Registry {
+	return f.sources[0].Registry
+}
+
+// tag 复制服务并写入来源标签, 不覆盖节点已有的元数据(可能参与签名), 优先级不参与签名总是写入
+func (s *Source) tag(service *micro.Service) *micro.Service {
+	service = CopyService(service)
+	for _, node := range service.Nodes {
+		md := maps.Clone(node.Metadata)
+		if md == nil {
+			md = make(map[string]string, len(s.Labels)+1)
+		}
+		for k, v := range s.Labels {
+			if _, ok := md[k]; !ok {
+				md[k] = v
+			}
+		}
+		md[micro.MetadataPriority] = strconv.Itoa(s.Priority)
+		node.Metadata = md
+	}
+	return service
+}
+
+func (f *federatedRegistry) Register(ctx context.Context, s *micro.Service) error {
+	return f.primary().Register(ctx, s)
+}
+
+func (f *federatedRegistry) Deregister(ctx context.Context, s *micro.Service) error {
+	return f.primary().Deregister(ctx, s)
+}
+
+// collect 并发查询全部数据源, 全部失败时返回首个错误
+func (f *federatedRegistry) collect(query func(micro.Registry) ([]*micro.Service, error)) ([]*micro.Service, error) {
+	results := make([][]*micro.Service, len(f.sources))
+	errs := make([]error, len(f.sources))
+	var wg sync.WaitGroup
+	for i, source := range f.sources {
+		wg.Add(1)
+		go func(i int, source *Source) {
+			defer wg.Done()
+			results[i], errs[i] = query(source.Registry)
+		}(i, source)
+	}
+	wg.Wait()
+
+	var services []*micro.Service
+	var failed error
+	var succeed bool
+	for i, source := range f.sources {
+		if errs[i] != nil {
+			if !errors.Is(errs[i], micro.ErrServiceNotFound) {
+				log.Warnf(context.Background(), "federated source %d query failed: %v", i, errs[i])
+				if failed == nil {
+					failed = errs[i]
+				}
+				continue
+			}
+		}
+		succeed = true
+		for _, service := range results[i] {
+			services = merge(services, source.tag(service))
+		}
+	}
+	if !succeed {
+		return nil, failed
+	}
+	return services, nil
+}
+
+// merge 按 name-主版本 合并节点
+func merge(services []*micro.Service, service *micro.Service) []*micro.Service {
+	for _, s := range services {
+		if s.Name == service.Name && s.Version == service.Version {
+			s.Nodes = append(s.Nodes, service.Nodes...)
+			return services
+		}
+	}
+	return append(services, service)
+}
+
+func (f *federatedRegistry) GetService(name string) ([]*micro.Service, error) {
+	services, err := f.collect(func(r micro.Registry) ([]*micro.Service, error) {
+		return r.GetService(name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(services) == 0 {
+		return nil, micro.ErrServiceNotFound
+	}
+	return services, nil
+}
+
+func (f *federatedRegistry) ListServices() ([]*micro.Service, error) {
+	services, err := f.collect(func(r micro.Registry) ([]*micro.Service, error) {
+		return r.ListServices()
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	return services, nil
+}
+
+/*
+Watch 合并全部数据源的事件
+单个数据源watch失败时在后台退避重建, 不影响其他数据源
+*/
+func (f *federatedRegistry) Watch(service string) (micro.Watcher, error) {
+	w := &federatedWatcher{
+		results: make(chan *micro.Result, 64),
+		stop:    make(chan struct{}),
+	}
+	for _, source := range f.sources {
+		go w.run(source, service)
+	}
+	return w, nil
+}
+
+// Namespace 最高优先级数据源的命名空间
+func (f *federatedRegistry) Namespace() string {
+	return Namespace(f.primary())
+}
+
+func (f *federatedRegistry) Name() string {
+	return "federated"
+}
+
+type federatedWatcher struct {
+	results chan *micro.Result
+	stop    chan struct{}
+	once    sync.Once
+}
+
+func (w *federatedWatcher) run(source *Source, service string) {
+	for attempt := 1; ; attempt++ {
+		watcher, err := source.Registry.Watch(service)
+		if err == nil {
+			attempt = 0
+			w.forward(source, watcher)
+		} else {
+			log.Warnf(context.Background(), "federated source %s watch failed: %v", source.Registry.Name(), err)
+		}
+		select {
+		case <-w.stop:
+			return
+		case <-time.After(utils.BackoffDelay(attempt + 1)):
+		}
+	}
+}
+
+// forward 转发事件直到watcher出错或停止
+func (w *federatedWatcher) forward(source *Source, watcher micro.Watcher) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-w.stop:
+		case <-done:
+		}
+		watcher.Stop()
+	}()
+	for {
+		res, err := watcher.Next()
+		if err != nil {
+			return
+		}
+		if res.Service != nil {
+			res = &micro.Result{Action: res.Action, Service: source.tag(res.Service)}
+		}
+		select {
+		case w.results <- res:
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+func (w *federatedWatcher) Next() (*micro.Result, error) {
+	select {
+	case r := <-w.results:
+		return r, nil
+	case <-w.stop:
+		return nil, errors.New("could not get next")
+	}
+}
+
+func (w *federatedWatcher) Stop() {
+	w.once.Do(func() {
+		close(w.stop)
+	})
+}
@@ -0,0 +1,72 @@
+package registry_test
+
+import (
+	"context"
+	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/registry"
+	"github.com/lolizeppelin/micro/selector"
+	"testing"
+	"time"
+)
+
+func TestFederatedRegistry(t *testing.T) {
+	ctx := context.Background()
+	dc1 := registry.NewMemoryRegistry()
+	dc2 := registry.NewMemoryRegistry()
+	r, _ := registry.NewFederatedRegistry(
+		&registry.Source{Registry: dc2, Priority: 1, Labels: map[string]string{"cluster": "dc2"}},
+		&registry.Source{Registry: dc1, Labels: map[string]string{"cluster": "dc1"}},
+	)
+	w, _ := r.Watch("lobby")
+	defer w.Stop()
+	time.Sleep(50 * time.Millisecond) // 等待各数据源watch建立
+
+	_ = r.Register(ctx, testService("a", "127.0.0.1:1")) // 注册到本地集群
+	_ = dc2.Register(ctx, testService("b", "127.0.0.2:1"))
+
+	services, err := r.GetService("lobby")
+	if err != nil || len(services) != 1 || len(services[0].Nodes) != 2 {
+		t.Fatalf("expect merged nodes, got %v %v", services, err)
+	}
+	clusters := map[string]string{}
+	for _, node := range services[0].Nodes {
+		clusters[node.Id] = node.Metadata["cluster"]
+	}
+	if clusters["a"] != "dc1" || clusters["b"] != "dc2" {
+		t.Fatalf("nodes not tagged with origin %v", clusters)
+	}
+	events := make(chan *micro.Result, 2)
+	go func() {
+		for i := 0; i < 2; i++ {
+			if res, err := w.Next(); err == nil {
+				events <- res
+			}
+		}
+	}()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-time.After(time.Second):
+			t.Fatal("watch events not merged")
+		case res := <-events:
+			if res.Service.Nodes[0].Metadata["cluster"] == "" {
+				t.Fatalf("event not tagged with origin %v", res)
+			}
+		}
+	}
+
+	s, _ := selector.NewSelector(selector.WithRegistry(r), selector.WithStrategy(selector.Random))
+	defer s.Close()
+	next, _ := s.Select("lobby")
+	if node, _ := next(); node.Id != "a" {
+		t.Fatalf("expect local node a, got %s", node.Id)
+	}
+	// 本地节点下线后切换到远端集群
+	_ = r.Register(ctx, &micro.Service{Name: "lobby", Version: 1, Nodes: []*micro.Node{
+		{Id: "a", Address: "127.0.0.1:1", Metadata: map[string]string{micro.MetadataStatus: micro.StatusQuarantined}},
+	}})
+	time.Sleep(50 * time.Millisecond)
+	next, _ = s.Select("lobby")
+	if node, _ := next(); node.Id != "b" {
+		t.Fatalf("expect failover to node b, got %s", node.Id)
+	}
+}
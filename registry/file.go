@@ -0,0 +1,450 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/log"
+	"github.com/lolizeppelin/micro/utils/flock"
+	hash "github.com/mitchellh/hashstructure/v2"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var DefaultPollInterval = time.Second
+
+// 注册写入的节点存活信息, 读取时移除, 不对外暴露
+const (
+	fileOwnerHost = "file.host"    // 注册进程所在主机
+	fileOwnerPid  = "file.pid"     // 注册进程pid
+	fileExpires   = "file.expires" // 设置TTL时的过期时间(unix秒)
+)
+
+// decoders 按文件扩展名选择解码方式, 当前仅支持json(无yaml依赖)
+var decoders = map[string]func([]byte, any) error{
+	".json": json.Unmarshal,
+}
+
+/*
+fileRegistry 基于本地json文件的注册中心, 用于边缘部署与本地开发
+1. path为文件时全部服务保存在该文件, 为目录时读取目录下全部*.json文件, 注册写入{service}.json
+2. 文件内容为服务列表 e.g [{"name": "lobby", "version": 1, "nodes": [{"id": "a", "address": "127.0.0.1:1780"}]}]
+3. 注册/注销在flock保护下读取-修改-重命名写入, 同主机多进程可安全共享
+4. 轮询文件变化, 按节点比较后向watcher发送create/update/delete事件
+5. 注册的节点记录主机与pid(设置TTL时记录过期时间), 读取时丢弃本机已退出进程或已过期的节点, 手工编写的节点不检查
+6. 文件扩展名需为.json, yaml等其他格式返回错误
+*/
+type fileRegistry struct {
+	path    string
+	dir     bool
+	options Options
+
+	sync.Mutex
+	watchers map[*memoryWatcher]struct{}
+	polling  bool
+	known    map[string]*record // service/node -> 最近一次读取的节点
+}
+
+func NewFileRegistry(path string, opts ...Option) (micro.Registry, error) {
+	options := Options{
+		Interval: DefaultPollInterval,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+	info, err := os.Stat(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if info == nil || !info.IsDir() {
+		if _, ok := decoders[strings.ToLower(filepath.Ext(path))]; !ok {
+			return nil, fmt.Errorf("file registry %s: unsupported format %q, only .json is supported",
+				path, filepath.Ext(path))
+		}
+	}
+	return &fileRegistry{
+		path:     path,
+		dir:      info != nil && info.IsDir(),
+		options:  options,
+		watchers: make(map[*memoryWatcher]struct{}),
+	}, nil
+}
+
+func (f *fileRegistry) lock(shared bool) (*flock.Flock, error) {
+	path := f.path + ".lock"
+	if f.dir {
+		path = filepath.Join(f.path, ".lock")
+	}
+	lock := flock.New(path)
+	var err error
+	if shared {
+		err = lock.RLock()
+	} else {
+		err = lock.Lock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// target 服务写入的文件
+func (f *fileRegistry) target(service string) string {
+	if !f.dir {
+		return f.path
+	}
+	return filepath.Join(f.path, strings.Replace(service, "/", "-", -1)+".json")
+}
+
+func readServices(path string) ([]*micro.Service, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(strings.TrimSpace(string(b))) == 0 {
+		return nil, nil
+	}
+	decode, ok := decoders[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return nil, fmt.Errorf("decode %s failed: unsupported format", path)
+	}
+	var services []*micro.Service
+	if err = decode(b, &services); err != nil {
+		return nil, fmt.Errorf("decode %s failed: %w", path, err)
+	}
+	return services, nil
+}
+
+// writeServices 写入临时文件后重命名, 读取方不会读到写入一半的文件
+func writeServices(path string, services []*micro.Service) error {
+	b, err := json.MarshalIndent(services, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// alive 注册进程是否存活, 未记录存活信息的节点(手工编写)视为存活
+func alive(node *micro.Node, host string, now time.Time) bool {
+	if v, ok := node.Metadata[fileExpires]; ok {
+		if expires, err := strconv.ParseInt(v, 10, 64); err == nil && now.Unix() > expires {
+			return false
+		}
+	}
+	if node.Metadata[fileOwnerHost] != host {
+		return true
+	}
+	pid, err := strconv.Atoi(node.Metadata[fileOwnerPid])
+	return err != nil || flock.Alive(pid)
+}
+
+// owned 移除存活信息
+func owned(node *micro.Node) *micro.Node {
+	if _, ok := node.Metadata[fileOwnerPid]; !ok {
+		return node
+	}
+	n := *node
+	n.Metadata = maps.Clone(node.Metadata)
+	delete(n.Metadata, fileOwnerHost)
+	delete(n.Metadata, fileOwnerPid)
+	delete(n.Metadata, fileExpires)
+	return &n
+}
+
+// load 读取全部存活节点, 每个服务只包含一个节点
+func (f *fileRegistry) load() ([]*micro.Service, error) {
+	lock, err := f.lock(true)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Close()
+
+	files := []string{f.path}
+	if f.dir {
+		if files, err = filepath.Glob(filepath.Join(f.path, "*.json")); err != nil {
+			return nil, err
+		}
+	}
+	host, _ := os.Hostname()
+	now := time.Now()
+	var nodes []*micro.Service
+	for _, file := range files {
+		services, err := readServices(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range services {
+			for _, node := range s.Nodes {
+				if !alive(node, host, now) {
+					continue
+				}
+				nodes = append(nodes, CopyService(&micro.Service{
+					Name:      s.Name,
+					Version:   s.Version,
+					Metadata:  s.Metadata,
+					Endpoints: s.Endpoints,
+					Nodes:     []*micro.Node{owned(node)},
+				}))
+			}
+		}
+	}
+	return nodes, nil
+}
+
+// update 在排他锁下修改服务文件, 先删除同id节点与已失效节点再执行add
+func (f *fileRegistry) update(s *micro.Service, add bool) error {
+	if len(s.Nodes) == 0 {
+		return errors.New("require at least one node")
+	}
+	if f.dir {
+		if err := os.MkdirAll(f.path, 0o755); err != nil {
+			return err
+		}
+	}
+	lock, err := f.lock(false)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	path := f.target(s.Name)
+	services, err := readServices(path)
+	if err != nil {
+		return err
+	}
+	ids := make(map[string]bool, len(s.Nodes))
+	for _, node := range s.Nodes {
+		ids[node.Id] = true
+	}
+	host, _ := os.Hostname()
+	now := time.Now()
+	var kept []*micro.Service
+	for _, service := range services {
+		var nodes []*micro.Node
+		for _, node := range service.Nodes {
+			if (service.Name != s.Name || !ids[node.Id]) && alive(node, host, now) {
+				nodes = append(nodes, node)
+			}
+		}
+		if len(nodes) == 0 {
+			continue
+		}
+		service.Nodes = nodes
+		kept = append(kept, service)
+	}
+	if add {
+		for _, node := range s.Nodes {
+			service := CopyService(&micro.Service{
+				Name:      s.Name,
+				Version:   s.Version,
+				Metadata:  s.Metadata,
+				Endpoints: s.Endpoints,
+				Nodes:     []*micro.Node{node},
+			})
+			if f.options.Keyring != nil {
+				if service, err = f.options.Keyring.Sign(service); err != nil {
+					return err
+				}
+			}
+			// 存活信息在签名后写入, 不参与签名
+			md := maps.Clone(service.Nodes[0].Metadata)
+			if md == nil {
+				md = make(map[string]string)
+			}
+			md[fileOwnerHost] = host
+			md[fileOwnerPid] = strconv.Itoa(os.Getpid())
+			if f.options.TTL > 0 {
+				md[fileExpires] = strconv.FormatInt(now.Add(f.options.TTL).Unix(), 10)
+			}
+			service.Nodes[0].Metadata = md
+			kept = append(kept, service)
+		}
+	}
+	if kept == nil {
+		kept = []*micro.Service{}
+	}
+	return writeServices(path, kept)
+}
+
+func (f *fileRegistry) Register(_ context.Context, s *micro.Service) error {
+	return f.update(s, true)
+}
+
+func (f *fileRegistry) Deregister(_ context.Context, s *micro.Service) error {
+	return f.update(s, false)
+}
+
+// group 按 name-主版本 合并节点
+func group(nodes []*micro.Service, name string) []*micro.Service {
+	versions := make(map[string]*micro.Service)
+	for _, n := range nodes {
+		if name != "" && n.Name != name {
+			continue
+		}
+		key := fmt.Sprintf("%s-%d", n.Name, n.Version)
+		s, ok := versions[key]
+		if !ok {
+			versions[key] = n
+			continue
+		}
+		s.Nodes = append(s.Nodes, n.Nodes...)
+	}
+	services := make([]*micro.Service, 0, len(versions))
+	for _, service := range versions {
+		services = append(services, service)
+	}
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].Name == services[j].Name {
+			return services[i].Version < services[j].Version
+		}
+		return services[i].Name < services[j].Name
+	})
+	return services
+}
+
+func (f *fileRegistry) GetService(name string) ([]*micro.Service, error) {
+	nodes, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	services := group(nodes, name)
+	if len(services) == 0 {
+		return nil, micro.ErrServiceNotFound
+	}
+	return services, nil
+}
+
+func (f *fileRegistry) ListServices() ([]*micro.Service, error) {
+	nodes, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	return group(nodes, ""), nil
+}
+
+// snapshot 读取节点并计算哈希
+func (f *fileRegistry) snapshot() (map[string]*record, error) {
+	nodes, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	records := make(map[string]*record, len(nodes))
+	for _, service := range nodes {
+		h, err := hash.Hash(service, hash.FormatV2, nil)
+		if err != nil {
+			return nil, err
+		}
+		records[service.Name+"/"+service.Nodes[0].Id] = &record{service: service, hash: h}
+	}
+	return records, nil
+}
+
+// poll 定时读取文件, 对比节点变化后通知watcher, 无watcher时退出
+func (f *fileRegistry) poll() {
+	ticker := time.NewTicker(f.options.Interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		f.Lock()
+		if len(f.watchers) == 0 {
+			f.polling = false
+			f.Unlock()
+			return
+		}
+		f.Unlock()
+
+		current, err := f.snapshot()
+		if err != nil {
+			log.Warnf(context.Background(), "file registry %s load failed: %v", f.path, err)
+			continue
+		}
+		var results []*micro.Result
+		f.Lock()
+		for key, r := range current {
+			old, ok := f.known[key]
+			switch {
+			case !ok:
+				results = append(results, &micro.Result{Action: "create", Service: r.service})
+			case old.hash != r.hash:
+				results = append(results, &micro.Result{Action: "update", Service: r.service})
+			}
+		}
+		for key, r := range f.known {
+			if _, ok := current[key]; !ok {
+				results = append(results, &micro.Result{Action: "delete", Service: r.service})
+			}
+		}
+		f.known = current
+		watchers := make([]*memoryWatcher, 0, len(f.watchers))
+		for w := range f.watchers {
+			watchers = append(watchers, w)
+		}
+		f.Unlock()
+
+		for _, res := range results {
+			for _, w := range watchers {
+				if w.service != "" && w.service != res.Service.Name {
+					continue
+				}
+				w.deliver(&micro.Result{Action: res.Action, Service: CopyService(res.Service)})
+			}
+		}
+	}
+}
+
+func (f *fileRegistry) Watch(service string) (micro.Watcher, error) {
+	w := newMemoryWatcher(service)
+	f.Lock()
+	defer f.Unlock()
+	if !f.polling {
+		// 以当前文件内容为基准, 之后的变化才发送事件
+		known, err := f.snapshot()
+		if err != nil {
+			return nil, err
+		}
+		f.known = known
+		f.polling = true
+		go f.poll()
+	}
+	f.watchers[w] = struct{}{}
+	w.remove = func() {
+		f.Lock()
+		delete(f.watchers, w)
+		f.Unlock()
+	}
+	return w, nil
+}
+
+func (f *fileRegistry) Namespace() string {
+	return f.options.Namespace
+}
+
+func (f *fileRegistry) Name() string {
+	return "file"
+}
@@ -0,0 +1,108 @@
+package registry_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/registry"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestFileRegistry(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "services.json")
+	r, err := registry.NewFileRegistry(path, registry.WithPollInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := r.Watch("lobby")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	// 另一进程共享同一文件
+	other, _ := registry.NewFileRegistry(path)
+	_ = r.Register(ctx, testService("a", "127.0.0.1:1"))
+	_ = other.Register(ctx, testService("b", "127.0.0.2:1"))
+
+	services, err := r.GetService("lobby")
+	if err != nil || len(services) != 1 || len(services[0].Nodes) != 2 {
+		t.Fatalf("expect 2 nodes, got %v %v", services, err)
+	}
+
+	events := make(chan *micro.Result, 3)
+	go func() {
+		for i := 0; i < 3; i++ {
+			res, err := w.Next()
+			if err != nil {
+				return
+			}
+			events <- res
+		}
+	}()
+	next := func() *micro.Result {
+		select {
+		case res := <-events:
+			return res
+		case <-time.After(time.Second):
+			t.Fatal("watch event timeout")
+		}
+		return nil
+	}
+	created := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		res := next()
+		if res.Action != "create" {
+			t.Fatalf("expect create, got %s", res.Action)
+		}
+		created[res.Service.Nodes[0].Id] = true
+	}
+	if !created["a"] || !created["b"] {
+		t.Fatalf("missing create events %v", created)
+	}
+
+	_ = other.Deregister(ctx, testService("b", "127.0.0.2:1"))
+	if res := next(); res.Action != "delete" || res.Service.Nodes[0].Id != "b" {
+		t.Fatalf("expect delete b, got %s %v", res.Action, res.Service.Nodes[0].Id)
+	}
+	services, _ = r.GetService("lobby")
+	if len(services) != 1 || len(services[0].Nodes) != 1 || services[0].Nodes[0].Id != "a" {
+		t.Fatalf("expect node a left, got %v", services)
+	}
+	if _, err = os.Stat(path + ".lock"); err != nil {
+		t.Fatalf("lock file missing: %v", err)
+	}
+}
+
+func TestFileRegistryLiveness(t *testing.T) {
+	if _, err := registry.NewFileRegistry(filepath.Join(t.TempDir(), "services.yaml")); err == nil {
+		t.Fatal("yaml file should be rejected")
+	}
+
+	path := filepath.Join(t.TempDir(), "services.json")
+	r, _ := registry.NewFileRegistry(path)
+	_ = r.Register(context.Background(), testService("a", "127.0.0.1:1"))
+	services, err := r.GetService("lobby")
+	if err != nil || len(services[0].Nodes[0].Metadata) != 0 {
+		t.Fatalf("owner metadata exposed: %v %v", services, err)
+	}
+
+	// 注册进程退出后节点失效
+	cmd := exec.Command("true")
+	if err = cmd.Run(); err != nil {
+		t.Skip(err)
+	}
+	b, _ := os.ReadFile(path)
+	b = bytes.Replace(b, []byte(`"file.pid": "`+strconv.Itoa(os.Getpid())+`"`),
+		[]byte(`"file.pid": "`+strconv.Itoa(cmd.Process.Pid)+`"`), 1)
+	_ = os.WriteFile(path, b, 0o644)
+	if _, err = r.GetService("lobby"); err != micro.ErrServiceNotFound {
+		t.Fatalf("node of dead process not dropped: %v", err)
+	}
+}
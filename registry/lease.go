@@ -0,0 +1,132 @@
+package registry
+
+import (
+	"context"
+	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/log"
+	"github.com/lolizeppelin/micro/utils"
+	clientV3 "go.etcd.io/etcd/client/v3"
+	"time"
+)
+
+const (
+	LeaseLost     = "lost"     // 租约丢失(过期/被撤销/续租流中断)
+	LeaseRestored = "restored" // 重新授权租约并写入节点
+)
+
+// LeaseEvent 节点租约事件
+type LeaseEvent struct {
+	Action  string
+	Service string
+	Node    string
+	Lease   int64
+	Error   error
+}
+
+// LeaseWatcher 支持租约事件通知的注册中心
+type LeaseWatcher interface {
+	LeaseEvents() <-chan *LeaseEvent
+}
+
+// keeper 单个节点的租约续期
+type keeper struct {
+	cancel  context.CancelFunc
+	lease   clientV3.LeaseID
+	service *micro.Service // 最近一次注册的节点信息
+}
+
+func (e *etcdRegistry) LeaseEvents() <-chan *LeaseEvent {
+	return e.events
+}
+
+func (e *etcdRegistry) notify(event *LeaseEvent) {
+	select {
+	case e.events <- event:
+	default:
+		log.Warnf(context.Background(), "lease event of %s node %s dropped", event.Service, event.Node)
+	}
+}
+
+// put 使用租约写入节点
+func (e *etcdRegistry) put(ctx context.Context, service *micro.Service, lease clientV3.LeaseID) error {
+	ctx, cancel := context.WithTimeout(ctx, e.options.Timeout)
+	defer cancel()
+	node := service.Nodes[0]
+	var err error
+	if lease > 0 {
+		_, err = e.client.Put(ctx, nodePath(e.root, service.Name, node.Id), encode(service), clientV3.WithLease(lease))
+	} else {
+		_, err = e.client.Put(ctx, nodePath(e.root, service.Name, node.Id), encode(service))
+	}
+	return err
+}
+
+// grant 授权新租约并写入节点
+func (e *etcdRegistry) grant(ctx context.Context, service *micro.Service) (clientV3.LeaseID, error) {
+	_ctx, cancel := context.WithTimeout(ctx, e.options.Timeout)
+	defer cancel()
+	lgr, err := e.client.Grant(_ctx, int64(e.options.TTL.Seconds()))
+	if err != nil {
+		return 0, err
+	}
+	if err = e.put(ctx, service, lgr.ID); err != nil {
+		return 0, err
+	}
+	return lgr.ID, nil
+}
+
+/*
+keepalive 通过KeepAlive流续租
+1. 续租流关闭说明租约丢失(过期/撤销)或连接长时间不可用, 发送lost事件
+2. 使用最近一次注册的节点信息重新授权租约并写入, 失败时退避重试, 成功后发送restored事件
+*/
+func (e *etcdRegistry) keepalive(ctx context.Context, key string, k *keeper) {
+	for {
+		e.RLock()
+		lease := k.lease
+		e.RUnlock()
+
+		ch, err := e.client.KeepAlive(ctx, lease)
+		if err == nil {
+			for range ch {
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		e.RLock()
+		service := k.service
+		e.RUnlock()
+		node := service.Nodes[0].Id
+		log.Warnf(ctx, "lease %d of %s node %s lost", lease, service.Name, node)
+		e.notify(&LeaseEvent{Action: LeaseLost, Service: service.Name, Node: node, Lease: int64(lease), Error: err})
+
+		for attempt := 1; ; attempt++ {
+			e.RLock()
+			service = k.service
+			e.RUnlock()
+			lease, err = e.grant(ctx, service)
+			if err == nil {
+				break
+			}
+			log.Errorf(ctx, "regrant lease of %s node %s failed: %v", service.Name, node, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(utils.BackoffDelay(attempt)):
+			}
+		}
+
+		e.Lock()
+		if e.keepers[key] != k { // 已注销
+			e.Unlock()
+			return
+		}
+		k.lease = lease
+		e.leases[key] = lease
+		e.Unlock()
+		log.Infof(ctx, "lease %d of %s node %s restored", lease, service.Name, node)
+		e.notify(&LeaseEvent{Action: LeaseRestored, Service: service.Name, Node: node, Lease: int64(lease)})
+	}
+}
@@ -0,0 +1,317 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/log"
+	hash "github.com/mitchellh/hashstructure/v2"
+	"sort"
+	"sync"
+	"time"
+)
+
+// record 单个节点的注册信息, 与etcd中一个key对应
+type record struct {
+	service *micro.Service
+	hash    uint64
+	expires time.Time
+}
+
+/*
+memoryRegistry 进程内注册中心, 用于测试与单进程部署
+1. 每个节点独立保存, 设置TTL时节点需在TTL内重新注册, 否则过期删除
+2. 节点新增/变更/删除(含过期)时向watcher发送create/update/delete事件
+3. 事件投递不阻塞, watcher缓冲满时标记为落后, Next返回ErrWatcherLagged, 调用方重新Watch并全量同步
+4. 设置TTL时后台清理过期节点, 通过Close停止
+*/
+type memoryRegistry struct {
+	options Options
+	events  sync.Mutex // 串行化变更与事件通知, 保证事件顺序
+
+	sync.RWMutex
+	records  map[string]map[string]*record
+	watchers map[*memoryWatcher]struct{}
+	schemas  map[string]*Schemas // 内容哈希 -> endpoint结构
+	exit     chan struct{}
+	once     sync.Once
+}
+
+// ErrWatcherLagged watcher未及时读取事件, 已丢弃事件, 需要重新同步
+var ErrWatcherLagged = errors.New("registry watcher lagged, resync required")
+
+func NewMemoryRegistry(opts ...Option) micro.Registry {
+	var options Options
+	for _, o := range opts {
+		o(&options)
+	}
+	m := &memoryRegistry{
+		options:  options,
+		records:  make(map[string]map[string]*record),
+		watchers: make(map[*memoryWatcher]struct{}),
+		exit:     make(chan struct{}),
+	}
+	if options.TTL > 0 {
+		go m.reap()
+	}
+	return m
+}
+
+func (m *memoryRegistry) reap() {
+	interval := m.options.TTL / 2
+	if interval < 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.exit:
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		var expired []*micro.Service
+		m.events.Lock()
+		m.Lock()
+		for name, nodes := range m.records {
+			for id, r := range nodes {
+				if r.expires.Before(now) {
+					delete(nodes, id)
+					expired = append(expired, r.service)
+				}
+			}
+			if len(nodes) == 0 {
+				delete(m.records, name)
+			}
+		}
+		m.Unlock()
+		for _, service := range expired {
+			log.Debugf(context.Background(), "service %s node %s expired", service.Name, service.Nodes[0].Id)
+			m.notify("delete", service)
+		}
+		m.events.Unlock()
+	}
+}
+
+func (m *memoryRegistry) notify(action string, service *micro.Service) {
+	m.RLock()
+	watchers := make([]*memoryWatcher, 0, len(m.watchers))
+	for w := range m.watchers {
+		if w.service == "" || w.service == service.Name {
+			watchers = append(watchers, w)
+		}
+	}
+	m.RUnlock()
+	for _, w := range watchers {
+		w.deliver(&micro.Result{Action: action, Service: CopyService(service)})
+	}
+}
+
+// Close 停止过期清理
+func (m *memoryRegistry) Close() error {
+	m.once.Do(func() {
+		close(m.exit)
+	})
+	return nil
+}
+
+func (m *memoryRegistry) Register(_ context.Context, s *micro.Service) error {
+	if len(s.Nodes) == 0 {
+		return errors.New("require at least one node")
+	}
+	for _, node := range s.Nodes {
+		service := CopyService(&micro.Service{
+			Name:      s.Name,
+			Version:   s.Version,
+			Metadata:  s.Metadata,
+			Endpoints: s.Endpoints,
+			Nodes:     []*micro.Node{node},
+		})
+		var err error
+		if m.options.Keyring != nil {
+			if service, err = m.options.Keyring.Sign(service); err != nil {
+				return err
+			}
+		}
+		h, err := hash.Hash(service.Nodes[0], hash.FormatV2, nil)
+		if err != nil {
+			return err
+		}
+
+		var expires time.Time
+		if m.options.TTL > 0 {
+			expires = time.Now().Add(m.options.TTL)
+		}
+		m.events.Lock()
+		m.Lock()
+		nodes, ok := m.records[s.Name]
+		if !ok {
+			nodes = make(map[string]*record)
+			m.records[s.Name] = nodes
+		}
+		old, exists := nodes[node.Id]
+		nodes[node.Id] = &record{service: service, hash: h, expires: expires}
+		m.Unlock()
+
+		switch {
+		case !exists:
+			m.notify("create", service)
+		case old.hash != h:
+			m.notify("update", service)
+		}
+		m.events.Unlock()
+	}
+	return nil
+}
+
+func (m *memoryRegistry) Deregister(_ context.Context, s *micro.Service) error {
+	if len(s.Nodes) == 0 {
+		return errors.New("require at least one node")
+	}
+	for _, node := range s.Nodes {
+		m.events.Lock()
+		m.Lock()
+		nodes := m.records[s.Name]
+		r, ok := nodes[node.Id]
+		if ok {
+			delete(nodes, node.Id)
+			if len(nodes) == 0 {
+				delete(m.records, s.Name)
+			}
+		}
+		m.Unlock()
+		if ok {
+			m.notify("delete", r.service)
+		}
+		m.events.Unlock()
+	}
+	return nil
+}
+
+// services 按 name-主版本 合并节点
+func (m *memoryRegistry) services(name string) []*micro.Service {
+	now := time.Now()
+	versions := make(map[string]*micro.Service)
+	m.RLock()
+	defer m.RUnlock()
+	for n, nodes := range m.records {
+		if name != "" && n != name {
+			continue
+		}
+		for _, r := range nodes {
+			if !r.expires.IsZero() && r.expires.Before(now) {
+				continue
+			}
+			key := fmt.Sprintf("%s-%d", r.service.Name, r.service.Version)
+			s, ok := versions[key]
+			if !ok {
+				s = CopyService(r.service)
+				versions[key] = s
+				continue
+			}
+			s.Nodes = append(s.Nodes, CopyService(r.service).Nodes...)
+		}
+	}
+	services := make([]*micro.Service, 0, len(versions))
+	for _, service := range versions {
+		sort.Slice(service.Nodes, func(i, j int) bool { return service.Nodes[i].Id < service.Nodes[j].Id })
+		services = append(services, service)
+	}
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].Name == services[j].Name {
+			return services[i].Version < services[j].Version
+		}
+		return services[i].Name < services[j].Name
+	})
+	return services
+}
+
+func (m *memoryRegistry) GetService(name string) ([]*micro.Service, error) {
+	services := m.services(name)
+	if len(services) == 0 {
+		return nil, micro.ErrServiceNotFound
+	}
+	return services, nil
+}
+
+func (m *memoryRegistry) ListServices() ([]*micro.Service, error) {
+	return m.services(""), nil
+}
+
+func (m *memoryRegistry) Watch(service string) (micro.Watcher, error) {
+	w := newMemoryWatcher(service)
+	m.Lock()
+	m.watchers[w] = struct{}{}
+	m.Unlock()
+	w.remove = func() {
+		m.Lock()
+		delete(m.watchers, w)
+		m.Unlock()
+	}
+	return w, nil
+}
+
+func (m *memoryRegistry) Namespace() string {
+	return m.options.Namespace
+}
+
+func (m *memoryRegistry) Name() string {
+	return "memory"
+}
+
+type memoryWatcher struct {
+	service string
+	results chan *micro.Result
+	stop    chan struct{}
+	lagged  chan struct{}
+	once    sync.Once
+	lag     sync.Once
+	remove  func()
+}
+
+func newMemoryWatcher(service string) *memoryWatcher {
+	return &memoryWatcher{
+		service: service,
+		results: make(chan *micro.Result, 64),
+		stop:    make(chan struct{}),
+		lagged:  make(chan struct{}),
+	}
+}
+
+// deliver 非阻塞投递, 缓冲已满时丢弃事件并标记落后
+func (w *memoryWatcher) deliver(res *micro.Result) {
+	select {
+	case w.results <- res:
+	case <-w.stop:
+	default:
+		w.lag.Do(func() {
+			log.Warnf(context.Background(), "registry watcher of %q lagged, drop events", w.service)
+			close(w.lagged)
+		})
+	}
+}
+
+func (w *memoryWatcher) Next() (*micro.Result, error) {
+	select {
+	case <-w.lagged:
+		return nil, ErrWatcherLagged
+	default:
+	}
+	select {
+	case r := <-w.results:
+		return r, nil
+	case <-w.lagged:
+		return nil, ErrWatcherLagged
+	case <-w.stop:
+		return nil, errors.New("could not get next")
+	}
+}
+
+func (w *memoryWatcher) Stop() {
+	w.once.Do(func() {
+		close(w.stop)
+		w.remove()
+	})
+}
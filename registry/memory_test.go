@@ -0,0 +1,93 @@
+package registry_test
+
+import (
+	"context"
+	"errors"
+	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/registry"
+	"github.com/lolizeppelin/micro/selector"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func testService(id, address string) *micro.Service {
+	return &micro.Service{
+		Name:      "lobby",
+		Version:   1,
+		Endpoints: map[string]*micro.Endpoint{"User.get": {Name: "User.get"}},
+		Nodes:     []*micro.Node{{Id: id, Address: address}},
+	}
+}
+
+func TestMemoryRegistry(t *testing.T) {
+	r := registry.NewMemoryRegistry(registry.WithTTL(1))
+	w, _ := r.Watch("lobby")
+	defer w.Stop()
+	ctx := context.Background()
+
+	next := func(action string) {
+		res, err := w.Next()
+		if err != nil || res.Action != action {
+			t.Fatalf("expect %s event, got %v %v", action, res, err)
+		}
+	}
+
+	_ = r.Register(ctx, testService("a", "127.0.0.1:1"))
+	next("create")
+	_ = r.Register(ctx, testService("a", "127.0.0.1:1")) // 无变更不发事件
+	_ = r.Register(ctx, testService("a", "127.0.0.1:2"))
+	next("update")
+	_ = r.Register(ctx, testService("b", "127.0.0.1:3"))
+	next("create")
+
+	services, err := r.GetService("lobby")
+	if err != nil || len(services) != 1 || len(services[0].Nodes) != 2 {
+		t.Fatalf("get service failed: %v %v", services, err)
+	}
+
+	_ = r.Deregister(ctx, testService("b", "127.0.0.1:3"))
+	next("delete")
+
+	// 通过selector(registry cache)选择节点
+	s, _ := selector.NewSelector(selector.WithRegistry(r))
+	n, err := s.Select("lobby")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node, _ := n(); node == nil || node.Id != "a" {
+		t.Fatalf("select node failed: %v", node)
+	}
+
+	// TTL过期删除
+	time.Sleep(1600 * time.Millisecond)
+	next("delete")
+	if _, err = r.GetService("lobby"); err != micro.ErrServiceNotFound {
+		t.Fatalf("expired service still exists: %v", err)
+	}
+}
+
+func TestMemoryWatcherLagged(t *testing.T) {
+	r := registry.NewMemoryRegistry(registry.WithTTL(60))
+	defer r.(io.Closer).Close()
+	w, _ := r.Watch("lobby")
+	defer w.Stop()
+
+	// watcher不读取事件时注册不阻塞
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_ = r.Register(context.Background(), testService(strconv.Itoa(i), "127.0.0.1:1"))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("register blocked by slow watcher")
+	}
+	if _, err := w.Next(); !errors.Is(err, registry.ErrWatcherLagged) {
+		t.Fatalf("expect lagged watcher, got %v", err)
+	}
+}
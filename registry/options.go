@@ -6,9 +6,13 @@ import (
 )
 
 type Options struct {
-	Client  *clientV3.Client
-	TTL     time.Duration
-	Timeout time.Duration
+	Client    *clientV3.Client
+	TTL       time.Duration
+	Timeout   time.Duration
+	Namespace string        // 命名空间, 隔离共享etcd的不同环境
+	Fallbacks []string      // 当前命名空间找不到服务时依次查找的命名空间
+	Interval  time.Duration // 文件注册中心轮询间隔
+	Keyring   *Keyring      // 注册记录签名密钥
 }
 
 type Option func(*Options)
@@ -31,3 +35,31 @@ func WithTimeout(seconds int32) Option {
 		o.Timeout = time.Second * time.Duration(seconds)
 	}
 }
+
+// WithNamespace scopes keys, watchers and broker topics to the namespace (e.g dev/staging).
+func WithNamespace(namespace string) Option {
+	return func(o *Options) {
+		o.Namespace = namespace
+	}
+}
+
+// WithFallbacks sets the namespaces looked up in order when a service is not found in the current namespace.
+func WithFallbacks(namespaces ...string) Option {
+	return func(o *Options) {
+		o.Fallbacks = namespaces
+	}
+}
+
+// WithPollInterval sets the file polling interval of the file registry.
+func WithPollInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.Interval = interval
+	}
+}
+
+// WithKeyring signs registered records with the keys of the service.
+func WithKeyring(k *Keyring) Option {
+	return func(o *Options) {
+		o.Keyring = k
+	}
+}
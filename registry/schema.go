@@ -0,0 +1,179 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/utils"
+	"github.com/lolizeppelin/micro/utils/jsonschema"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+	clientV3 "go.etcd.io/etcd/client/v3"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EndpointSchema endpoint请求/返回结构
+type EndpointSchema struct {
+	Name       string                  `json:"name"`
+	Comment    *jsonschema.Comment     `json:"comment,omitempty"`
+	PrimaryKey bool                    `json:"pk,omitempty"`
+	Internal   bool                    `json:"internal,omitempty"`
+	Query      map[string]any          `json:"query,omitempty"`
+	Request    *jsonschema.ContentBody `json:"request,omitempty"`
+	Response   *jsonschema.ContentBody `json:"response,omitempty"`
+}
+
+// Schemas 服务版本的全部endpoint结构, 以内容哈希为key单独保存, 节点元数据中仅记录哈希
+type Schemas struct {
+	Service   string                     `json:"service"`
+	Version   micro.Version              `json:"version"`
+	Endpoints map[string]*EndpointSchema `json:"endpoints"`
+}
+
+// Hash 内容哈希
+func (s *Schemas) Hash() (string, error) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	return utils.Sha256Sum(b), nil
+}
+
+// SchemaRegistry 支持保存endpoint结构的注册中心
+type SchemaRegistry interface {
+	// PutSchema 保存结构, 返回内容哈希
+	PutSchema(ctx context.Context, schemas *Schemas) (string, error)
+	// GetSchema 获取服务版本(忽略patch)的结构, version为nil时返回最新版本
+	GetSchema(service string, version *micro.Version) (*Schemas, error)
+}
+
+// schemaHash 从服务节点中查找版本匹配的结构哈希, 版本为nil时取最高版本
+func schemaHash(services []*micro.Service, version *micro.Version) string {
+	var nodes []*micro.Node
+	for _, service := range services {
+		for _, node := range service.Nodes {
+			if node.Metadata[micro.MetadataSchema] == "" {
+				continue
+			}
+			if version != nil && node.Version.Compare(*version) != 0 {
+				continue
+			}
+			nodes = append(nodes, node)
+		}
+	}
+	if len(nodes) == 0 {
+		return ""
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Version.Compare(nodes[j].Version, true) > 0
+	})
+	return nodes[0].Metadata[micro.MetadataSchema]
+}
+
+var schemaPrefix = "/micro/schemas/"
+
+// SchemaTTL 结构租约时长, 使用该结构的节点每次注册时续约, 全部节点下线后过期删除
+var SchemaTTL = 24 * time.Hour
+
+func schemaPath(namespace, service, hash string) string {
+	return path.Join(schemaPrefix, strings.Replace(namespace, "/", "-", -1),
+		strings.Replace(service, "/", "-", -1), hash)
+}
+
+/*
+PutSchema 结构以内容哈希为key, 相同构建的节点共享
+1. 已存在且有租约时仅续约, 否则以新租约写入(兼容无租约的旧key)
+2. 节点每次注册时调用以续约
+*/
+func (e *etcdRegistry) PutSchema(ctx context.Context, schemas *Schemas) (string, error) {
+	hash, err := schemas.Hash()
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(schemas)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, e.options.Timeout)
+	defer cancel()
+	key := schemaPath(e.options.Namespace, schemas.Service, hash)
+	rsp, err := e.client.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	if len(rsp.Kvs) > 0 && rsp.Kvs[0].Lease != 0 {
+		_, err = e.client.KeepAliveOnce(ctx, clientV3.LeaseID(rsp.Kvs[0].Lease))
+		if err == nil || !errors.Is(err, rpctypes.ErrLeaseNotFound) {
+			return hash, err
+		}
+		// 租约已过期, 重新写入
+	}
+	lease, err := e.client.Grant(ctx, int64(SchemaTTL.Seconds()))
+	if err != nil {
+		return "", err
+	}
+	if _, err = e.client.Put(ctx, key, string(b), clientV3.WithLease(lease.ID)); err != nil {
+		_, _ = e.client.Revoke(context.Background(), lease.ID)
+		return "", err
+	}
+	return hash, nil
+}
+
+func (e *etcdRegistry) GetSchema(service string, version *micro.Version) (*Schemas, error) {
+	for _, namespace := range e.namespaces() {
+		services, err := e.getService(namespacePath(namespace), service)
+		if errors.Is(err, micro.ErrServiceNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		hash := schemaHash(services, version)
+		if hash == "" {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), e.options.Timeout)
+		rsp, err := e.client.Get(ctx, schemaPath(namespace, service, hash))
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		if len(rsp.Kvs) == 0 {
+			continue
+		}
+		schemas := new(Schemas)
+		if err = json.Unmarshal(rsp.Kvs[0].Value, schemas); err != nil {
+			return nil, err
+		}
+		return schemas, nil
+	}
+	return nil, micro.ErrServiceNotFound
+}
+
+func (m *memoryRegistry) PutSchema(_ context.Context, schemas *Schemas) (string, error) {
+	hash, err := schemas.Hash()
+	if err != nil {
+		return "", err
+	}
+	m.Lock()
+	defer m.Unlock()
+	if m.schemas == nil {
+		m.schemas = make(map[string]*Schemas)
+	}
+	m.schemas[hash] = schemas
+	return hash, nil
+}
+
+func (m *memoryRegistry) GetSchema(service string, version *micro.Version) (*Schemas, error) {
+	hash := schemaHash(m.services(service), version)
+	m.RLock()
+	defer m.RUnlock()
+	schemas, ok := m.schemas[hash]
+	if !ok {
+		return nil, micro.ErrServiceNotFound
+	}
+	return schemas, nil
+}
@@ -0,0 +1,349 @@
+package registry
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/config"
+	"github.com/lolizeppelin/micro/log"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"maps"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// KeyringKey 服务签名密钥在配置中心的路径, 完整key为 KeyringKey + 服务名
+	KeyringKey = "registry/keys/"
+
+	AlgorithmHMAC    = "hmac-sha256"
+	AlgorithmEd25519 = "ed25519"
+)
+
+var (
+	ErrUnsigned         = errors.New("registry record unsigned")
+	ErrInvalidSignature = errors.New("registry record signature invalid")
+)
+
+// SigningKey 服务签名密钥, 二进制字段在json中为base64
+type SigningKey struct {
+	ID         string `json:"id"`
+	Algorithm  string `json:"algorithm"`
+	Secret     []byte `json:"secret,omitempty"`      // hmac密钥
+	PublicKey  []byte `json:"public_key,omitempty"`  // ed25519公钥
+	PrivateKey []byte `json:"private_key,omitempty"` // ed25519私钥(64字节或32字节seed), 建议通过Keyring.AddPrivateKey本地提供
+}
+
+// ServiceKeys 服务密钥列表, 第一个可签名的密钥用于签名, 全部密钥用于验证
+type ServiceKeys struct {
+	Service string        `json:"service"`
+	Keys    []*SigningKey `json:"keys"`
+}
+
+/*
+Keyring 注册记录签名密钥
+1. 为服务配置了密钥时, 注册方签名节点记录, 缓存/选择器丢弃未签名或验证失败的节点
+2. 未配置密钥的服务不签名也不验证
+3. 轮换: 追加新密钥(验证方同时接受新旧密钥) -> 新密钥调整到首位(注册方下次注册使用新密钥) -> 删除旧密钥
+4. 签名覆盖服务名,版本,元数据,endpoints以及节点信息, 签名后写入的节点元数据(e.g 联邦注册中心标签)不参与验证
+5. 联邦注册中心不覆盖已有元数据, 来源优先级(micro.MetadataPriority)由联邦注册中心写入, 不参与签名
+*/
+type Keyring struct {
+	sync.RWMutex
+	services map[string]*ServiceKeys
+	private  map[string]ed25519.PrivateKey // key id -> 本地ed25519私钥
+}
+
+func NewKeyring(keys ...*ServiceKeys) *Keyring {
+	k := &Keyring{
+		services: make(map[string]*ServiceKeys),
+		private:  make(map[string]ed25519.PrivateKey),
+	}
+	for _, sk := range keys {
+		k.Store(sk)
+	}
+	return k
+}
+
+func (k *Keyring) Store(keys *ServiceKeys) {
+	k.Lock()
+	defer k.Unlock()
+	k.services[keys.Service] = keys
+}
+
+func (k *Keyring) Delete(service string) {
+	k.Lock()
+	defer k.Unlock()
+	delete(k.services, service)
+}
+
+// AddPrivateKey 本地提供ed25519私钥, 配置中心只需保存公钥
+func (k *Keyring) AddPrivateKey(id string, key ed25519.PrivateKey) {
+	k.Lock()
+	defer k.Unlock()
+	k.private[id] = key
+}
+
+func (k *Keyring) keys(service string) []*SigningKey {
+	k.RLock()
+	defer k.RUnlock()
+	if sk, ok := k.services[service]; ok {
+		return sk.Keys
+	}
+	return nil
+}
+
+func (k *Keyring) privateKey(key *SigningKey) ed25519.PrivateKey {
+	switch len(key.PrivateKey) {
+	case ed25519.PrivateKeySize:
+		return key.PrivateKey
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(key.PrivateKey)
+	}
+	k.RLock()
+	defer k.RUnlock()
+	return k.private[key.ID]
+}
+
+func (k *Keyring) sign(key *SigningKey, payload []byte) ([]byte, bool) {
+	switch key.Algorithm {
+	case AlgorithmHMAC:
+		if len(key.Secret) == 0 {
+			return nil, false
+		}
+		mac := hmac.New(sha256.New, key.Secret)
+		mac.Write(payload)
+		return mac.Sum(nil), true
+	case AlgorithmEd25519:
+		private := k.privateKey(key)
+		if private == nil {
+			return nil, false
+		}
+		return ed25519.Sign(private, payload), true
+	}
+	return nil, false
+}
+
+func (k *Keyring) verify(key *SigningKey, payload, signature []byte) bool {
+	switch key.Algorithm {
+	case AlgorithmHMAC:
+		expected, ok := k.sign(key, payload)
+		return ok && hmac.Equal(expected, signature)
+	case AlgorithmEd25519:
+		public := ed25519.PublicKey(key.PublicKey)
+		if len(public) != ed25519.PublicKeySize {
+			private := k.privateKey(key)
+			if private == nil {
+				return false
+			}
+			public = private.Public().(ed25519.PublicKey)
+		}
+		return ed25519.Verify(public, payload, signature)
+	}
+	return false
+}
+
+// payload 签名内容, json编码map时key有序, 结果稳定
+func payload(s *micro.Service, node *micro.Node, fields []string) ([]byte, error) {
+	n := *node
+	n.Metadata = make(map[string]string, len(fields))
+	for _, field := range fields {
+		v, ok := node.Metadata[field]
+		if !ok {
+			return nil, fmt.Errorf("signed metadata %s missing", field)
+		}
+		n.Metadata[field] = v
+	}
+	return json.Marshal(&micro.Service{
+		Name:      s.Name,
+		Version:   s.Version,
+		Metadata:  s.Metadata,
+		Endpoints: s.Endpoints,
+		Nodes:     []*micro.Node{&n},
+	})
+}
+
+/*
+Sign 返回签名后的服务副本, 签名写入节点元数据 micro.MetadataSignature
+格式为 {key id}:{参与签名的元数据key, 逗号分隔}:{base64签名}
+服务未配置密钥时返回原服务
+*/
+func (k *Keyring) Sign(s *micro.Service) (*micro.Service, error) {
+	keys := k.keys(s.Name)
+	if len(keys) == 0 {
+		return s, nil
+	}
+	signed := CopyService(s)
+	for _, node := range signed.Nodes {
+		md := maps.Clone(node.Metadata)
+		if md == nil {
+			md = make(map[string]string)
+		}
+		delete(md, micro.MetadataSignature)
+		node.Metadata = md
+		fields := make([]string, 0, len(md))
+		for field := range md {
+			if field == micro.MetadataPriority {
+				continue
+			}
+			if strings.ContainsAny(field, ",:") {
+				return nil, fmt.Errorf("metadata %s can not be signed", field)
+			}
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		data, err := payload(signed, node, fields)
+		if err != nil {
+			return nil, err
+		}
+		var done bool
+		for _, key := range keys {
+			signature, ok := k.sign(key, data)
+			if !ok {
+				continue
+			}
+			md[micro.MetadataSignature] = strings.Join([]string{key.ID, strings.Join(fields, ","),
+				base64.RawURLEncoding.EncodeToString(signature)}, ":")
+			done = true
+			break
+		}
+		if !done {
+			return nil, fmt.Errorf("no signing key available for service %s", s.Name)
+		}
+	}
+	return signed, nil
+}
+
+// Verify 验证节点签名, 服务未配置密钥时不验证
+func (k *Keyring) Verify(s *micro.Service, node *micro.Node) error {
+	keys := k.keys(s.Name)
+	if len(keys) == 0 {
+		return nil
+	}
+	value, ok := node.Metadata[micro.MetadataSignature]
+	if !ok {
+		return ErrUnsigned
+	}
+	parts := strings.SplitN(value, ":", 3)
+	if len(parts) != 3 {
+		return ErrInvalidSignature
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	var fields []string
+	if parts[1] != "" {
+		fields = strings.Split(parts[1], ",")
+	}
+	data, err := payload(s, node, fields)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	for _, key := range keys {
+		if key.ID == parts[0] && k.verify(key, data, signature) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// Verified 返回验证通过的节点, 不修改原服务
+func (k *Keyring) Verified(services []*micro.Service) []*micro.Service {
+	var verified []*micro.Service
+	for _, s := range services {
+		var nodes []*micro.Node
+		for _, node := range s.Nodes {
+			if err := k.Verify(s, node); err != nil {
+				log.Warnf(context.Background(), "drop service %s node %s: %v", s.Name, node.Id, err)
+				continue
+			}
+			nodes = append(nodes, node)
+		}
+		if len(nodes) == 0 {
+			continue
+		}
+		if len(nodes) == len(s.Nodes) {
+			verified = append(verified, s)
+			continue
+		}
+		verified = append(verified, &micro.Service{
+			Name:      s.Name,
+			Version:   s.Version,
+			Metadata:  s.Metadata,
+			Endpoints: s.Endpoints,
+			Nodes:     nodes,
+		})
+	}
+	return verified
+}
+
+func decodeKeys(key string, value []byte) (*ServiceKeys, error) {
+	keys := new(ServiceKeys)
+	if err := json.Unmarshal(value, keys); err != nil {
+		return nil, err
+	}
+	keys.Service = path.Base(key)
+	for _, k := range keys.Keys {
+		if k.ID == "" || strings.Contains(k.ID, ":") {
+			return nil, fmt.Errorf("invalid key id %q", k.ID)
+		}
+	}
+	return keys, nil
+}
+
+// Load 从配置中心加载全部服务密钥
+func (k *Keyring) Load(ctx context.Context, cfg *config.EtcdConfig) error {
+	kvs, err := cfg.List(ctx, KeyringKey)
+	if err != nil {
+		if errors.Is(err, micro.ErrConfigFound) {
+			return nil
+		}
+		return err
+	}
+	for _, kv := range kvs {
+		keys, e := decodeKeys(string(kv.Key), kv.Value)
+		if e != nil {
+			log.Errorf(ctx, "decode registry keys %s failed: %s", kv.Key, e.Error())
+			continue
+		}
+		k.Store(keys)
+	}
+	return nil
+}
+
+// Watch 加载密钥并通过配置中心监听轮换
+func (k *Keyring) Watch(ctx context.Context, cfg *config.EtcdConfig) error {
+	if err := k.Load(ctx, cfg); err != nil {
+		return err
+	}
+	cfg.Watch(ctx, KeyringKey, func(ctx context.Context, _ string, events []*clientv3.Event, err error) {
+		if err != nil {
+			log.Errorf(ctx, "registry keys watcher failed: %s", err.Error())
+			return
+		}
+		for _, ev := range events {
+			key := string(ev.Kv.Key)
+			switch ev.Type {
+			case clientv3.EventTypePut:
+				keys, e := decodeKeys(key, ev.Kv.Value)
+				if e != nil {
+					log.Errorf(ctx, "decode registry keys %s failed: %s", key, e.Error())
+					continue
+				}
+				k.Store(keys)
+			case clientv3.EventTypeDelete:
+				k.Delete(path.Base(key))
+			}
+		}
+	})
+	return nil
+}
@@ -0,0 +1,120 @@
+package registry_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/registry"
+	"github.com/lolizeppelin/micro/registry/cache"
+	"testing"
+	"time"
+)
+
+func TestKeyring(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(nil)
+	signer := registry.NewKeyring(&registry.ServiceKeys{Service: "lobby", Keys: []*registry.SigningKey{
+		{ID: "k1", Algorithm: registry.AlgorithmEd25519, PublicKey: public},
+	}})
+	signer.AddPrivateKey("k1", private)
+	verifier := registry.NewKeyring(&registry.ServiceKeys{Service: "lobby", Keys: []*registry.SigningKey{
+		{ID: "k1", Algorithm: registry.AlgorithmEd25519, PublicKey: public},
+	}})
+
+	s := testService("a", "127.0.0.1:1")
+	s.Nodes[0].Metadata = map[string]string{micro.MetadataWeight: "10"}
+	signed, err := signer.Sign(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Nodes[0].Metadata[micro.MetadataSignature]; ok {
+		t.Fatal("sign should not modify the original service")
+	}
+	if err = verifier.Verify(signed, signed.Nodes[0]); err != nil {
+		t.Fatal(err)
+	}
+	// 签名后追加的元数据不参与验证
+	signed.Nodes[0].Metadata["cluster"] = "dc1"
+	if err = verifier.Verify(signed, signed.Nodes[0]); err != nil {
+		t.Fatalf("appended metadata should not break signature: %v", err)
+	}
+	signed.Nodes[0].Metadata[micro.MetadataWeight] = "1000"
+	if err = verifier.Verify(signed, signed.Nodes[0]); !errors.Is(err, registry.ErrInvalidSignature) {
+		t.Fatalf("expect tampered metadata rejected, got %v", err)
+	}
+	signed.Nodes[0].Metadata[micro.MetadataWeight] = "10"
+	signed.Nodes[0].Address = "10.0.0.1:1"
+	if err = verifier.Verify(signed, signed.Nodes[0]); !errors.Is(err, registry.ErrInvalidSignature) {
+		t.Fatalf("expect tampered address rejected, got %v", err)
+	}
+	if err = verifier.Verify(s, s.Nodes[0]); !errors.Is(err, registry.ErrUnsigned) {
+		t.Fatalf("expect unsigned rejected, got %v", err)
+	}
+
+	// 轮换: 新hmac密钥在前, 旧签名仍可验证
+	old, _ := signer.Sign(s)
+	verifier.Store(&registry.ServiceKeys{Service: "lobby", Keys: []*registry.SigningKey{
+		{ID: "k2", Algorithm: registry.AlgorithmHMAC, Secret: []byte("secret")},
+		{ID: "k1", Algorithm: registry.AlgorithmEd25519, PublicKey: public},
+	}})
+	if err = verifier.Verify(old, old.Nodes[0]); err != nil {
+		t.Fatalf("old key should verify during rotation: %v", err)
+	}
+	rotated, err := verifier.Sign(s)
+	if err != nil || verifier.Verify(rotated, rotated.Nodes[0]) != nil {
+		t.Fatalf("sign with new key failed %v", err)
+	}
+}
+
+func TestVerifiedCache(t *testing.T) {
+	keyring := registry.NewKeyring(&registry.ServiceKeys{Service: "lobby", Keys: []*registry.SigningKey{
+		{ID: "k1", Algorithm: registry.AlgorithmHMAC, Secret: []byte("secret")},
+	}})
+	ctx := context.Background()
+	r := registry.NewMemoryRegistry(registry.WithKeyring(keyring))
+	_ = r.Register(ctx, testService("a", "127.0.0.1:1"))
+	services, _ := r.GetService("lobby")
+
+	// 未签名与篡改地址的节点写入同一注册中心
+	rogue := testService("rogue", "10.0.0.1:1")
+	tampered, _ := keyring.Sign(testService("tampered", "10.0.0.1:1"))
+	tampered.Nodes[0].Address = "10.0.0.2:1"
+	mixed := registry.NewMemoryRegistry()
+	_ = mixed.Register(ctx, services[0])
+	_ = mixed.Register(ctx, rogue)
+	_ = mixed.Register(ctx, tampered)
+
+	rc := cache.New(mixed, time.Minute, cache.WithKeyring(keyring))
+	defer rc.Stop()
+	services, err := rc.GetService("lobby")
+	if err != nil || len(services) != 1 || len(services[0].Nodes) != 1 || services[0].Nodes[0].Id != "a" {
+		t.Fatalf("expect only signed node a, got %v %v", services, err)
+	}
+}
+
+func TestVerifiedFederation(t *testing.T) {
+	keyring := registry.NewKeyring(&registry.ServiceKeys{Service: "lobby", Keys: []*registry.SigningKey{
+		{ID: "k1", Algorithm: registry.AlgorithmHMAC, Secret: []byte("secret")},
+	}})
+	remote := registry.NewMemoryRegistry(registry.WithKeyring(keyring))
+	s := testService("a", "127.0.0.1:1")
+	s.Nodes[0].Metadata = map[string]string{micro.MetadataRegion: "dc2", micro.MetadataPriority: "0"}
+	_ = remote.Register(context.Background(), s)
+
+	r, _ := registry.NewFederatedRegistry(
+		&registry.Source{Registry: registry.NewMemoryRegistry()},
+		&registry.Source{Registry: remote, Priority: 1, Labels: map[string]string{micro.MetadataRegion: "dc1", "cluster": "dc2"}},
+	)
+	services, err := r.GetService("lobby")
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := services[0].Nodes[0]
+	if node.Metadata[micro.MetadataRegion] != "dc2" || node.Metadata["cluster"] != "dc2" ||
+		node.Metadata[micro.MetadataPriority] != "1" {
+		t.Fatalf("unexpected federation metadata %v", node.Metadata)
+	}
+	if err = keyring.Verify(services[0], node); err != nil {
+		t.Fatalf("federation tags broke signature: %v", err)
+	}
+}
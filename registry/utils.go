@@ -149,19 +149,35 @@ func Remove(old, del []*micro.Service) []*micro.Service {
 	return services
 }
 
-// Topic 获取需要发送的主题
-func Topic(service string, version *micro.Version, node string) string {
+// Namespaced 支持命名空间的注册中心
+type Namespaced interface {
+	Namespace() string
+}
+
+// Namespace 注册中心的命名空间, 未设置或不支持时返回空
+func Namespace(r micro.Registry) string {
+	if n, ok := r.(Namespaced); ok {
+		return n.Namespace()
+	}
+	return ""
+}
+
+// Topic 获取需要发送的主题, 设置命名空间时以命名空间为前缀
+func Topic(namespace, service string, version *micro.Version, node string) string {
 	var topic string
 	if node != "" {
 		topic = fmt.Sprintf("%s.node-%s.endpoints", service, node)
 	} else {
 		topic = fmt.Sprintf("%s.v%s.endpoints", service, version.Main())
 	}
+	if namespace != "" {
+		topic = namespace + "." + topic
+	}
 	return topic
 }
 
-func Topics(service string, version *micro.Version, node string) []string {
-	t1 := Topic(service, version, "")
-	t2 := Topic(service, version, node)
+func Topics(namespace, service string, version *micro.Version, node string) []string {
+	t1 := Topic(namespace, service, version, "")
+	t2 := Topic(namespace, service, version, node)
 	return []string{t1, t2}
 }
@@ -7,7 +7,28 @@ import (
 
 func TestUtils(t *testing.T) {
 
-	s := nodePath("lobby", "asdf")
+	s := nodePath(prefix, "lobby", "asdf")
 	fmt.Println(s)
 
 }
+
+func TestNamespace(t *testing.T) {
+	root := namespacePath("dev")
+	key := nodePath(root, "lobby", "asdf")
+	if key != "/micro/registry/dev/lobby/asdf" {
+		t.Fatalf("unexpected node path %s", key)
+	}
+	if !scoped(root, []byte(key)) {
+		t.Fatalf("%s should be scoped to dev", key)
+	}
+	// 默认命名空间不包含其他命名空间的节点
+	if scoped(namespacePath(""), []byte(key)) {
+		t.Fatalf("%s should not be scoped to default namespace", key)
+	}
+	if Topic("dev", "lobby", nil, "asdf") != "dev.lobby.node-asdf.endpoints" {
+		t.Fatal("topic should be scoped to namespace")
+	}
+	if key = schemaPath("team/dev", "lobby", "hash"); key != "/micro/schemas/team-dev/lobby/hash" {
+		t.Fatalf("unexpected schema path %s", key)
+	}
+}
@@ -4,76 +4,294 @@ import (
 	"context"
 	"errors"
 	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/log"
+	"github.com/lolizeppelin/micro/utils"
+	hash "github.com/mitchellh/hashstructure/v2"
+	"sync"
 	"time"
 
 	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
+// maxResumes 连续恢复失败次数上限, 超过后返回错误由调用方重建watcher
+const maxResumes = 3
+
+// WatchHealth watch运行状态, 用于监控watcher是否频繁恢复
+type WatchHealth struct {
+	Watchers    int64     // 运行中的watcher
+	Resumes     int64     // watch通道关闭后按revision恢复次数
+	Resyncs     int64     // revision被压缩后全量同步次数
+	Revision    int64     // 最近处理的revision
+	LastError   string    // 最近一次错误
+	LastErrorAt time.Time // 最近一次错误时间
+}
+
+// WatchReporter 可报告watch状态的注册中心
+type WatchReporter interface {
+	WatchHealth() WatchHealth
+}
+
+type watchStats struct {
+	sync.Mutex
+	health WatchHealth
+}
+
+func (s *watchStats) update(fn func(h *WatchHealth)) {
+	s.Lock()
+	fn(&s.health)
+	s.Unlock()
+}
+
+func (s *watchStats) failed(err error) {
+	s.update(func(h *WatchHealth) {
+		h.LastError = err.Error()
+		h.LastErrorAt = time.Now()
+	})
+}
+
+func (s *watchStats) get() WatchHealth {
+	s.Lock()
+	defer s.Unlock()
+	return s.health
+}
+
+/*
+etcdWatcher 基于revision的watch
+1. 创建时读取全量数据并记录revision, 从revision+1开始watch, 期间变更不会丢失
+2. watch通道关闭或取消时从最近处理的revision+1恢复
+3. revision被压缩(ErrCompacted)时重新读取全量数据, 与已发送的节点比较生成create/update/delete事件
+*/
 type etcdWatcher struct {
+	root    string // 命名空间根路径
+	path    string // watch路径
+	ctx     context.Context
+	cancel  context.CancelFunc
 	stop    chan bool
 	w       clientv3.WatchChan
 	client  *clientv3.Client
 	timeout time.Duration
+	stats   *watchStats
+
+	rev     int64                     // 最近处理的revision
+	known   map[string]*micro.Service // 已发送的节点 key -> service
+	pending []*micro.Result
 }
 
 func newEtcdWatcher(r *etcdRegistry, timeout time.Duration, service string) (micro.Watcher, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	stop := make(chan bool, 1)
 
+	watchPath := r.root
+	if len(service) > 0 {
+		watchPath = servicePath(r.root, service) + "/"
+	}
+
+	ew := &etcdWatcher{
+		root:    r.root,
+		path:    watchPath,
+		ctx:     ctx,
+		cancel:  cancel,
+		stop:    stop,
+		client:  r.client,
+		timeout: timeout,
+		stats:   r.stats,
+	}
+	known, rev, err := ew.load()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	ew.known = known
+	ew.rev = rev
+	ew.watch()
+
 	go func() {
 		<-stop
 		cancel()
 	}()
+	ew.stats.update(func(h *WatchHealth) { h.Watchers++ })
+	return ew, nil
+}
 
-	watchPath := prefix
-	if len(service) > 0 {
-		watchPath = servicePath(service) + "/"
+// load 读取watch路径下的全部节点与当前revision
+func (ew *etcdWatcher) load() (map[string]*micro.Service, int64, error) {
+	ctx, cancel := context.WithTimeout(ew.ctx, ew.timeout)
+	defer cancel()
+	rsp, err := ew.client.Get(ctx, ew.path, clientv3.WithPrefix())
+	if err != nil {
+		return nil, 0, err
+	}
+	known := make(map[string]*micro.Service, len(rsp.Kvs))
+	for _, kv := range rsp.Kvs {
+		if !scoped(ew.root, kv.Key) {
+			continue
+		}
+		if service := decode(kv.Value); service != nil {
+			known[string(kv.Key)] = service
+		}
 	}
+	return known, rsp.Header.Revision, nil
+}
 
-	return &etcdWatcher{
-		stop:    stop,
-		w:       r.client.Watch(ctx, watchPath, clientv3.WithPrefix(), clientv3.WithPrevKV()),
-		client:  r.client,
-		timeout: timeout,
-	}, nil
+func (ew *etcdWatcher) watch() {
+	ew.w = ew.client.Watch(ew.ctx, ew.path, clientv3.WithPrefix(), clientv3.WithPrevKV(), clientv3.WithRev(ew.rev+1))
 }
 
-func (ew *etcdWatcher) Next() (*micro.Result, error) {
-	for res := range ew.w {
-		if res.Err() != nil {
-			return nil, res.Err()
+// resync 全量读取并与已发送节点比较
+func (ew *etcdWatcher) resync() error {
+	known, rev, err := ew.load()
+	if err != nil {
+		return err
+	}
+	for key, service := range known {
+		old, ok := ew.known[key]
+		switch {
+		case !ok:
+			ew.pending = append(ew.pending, &micro.Result{Action: "create", Service: service})
+		case !sameService(old, service):
+			ew.pending = append(ew.pending, &micro.Result{Action: "update", Service: service})
 		}
-		if res.Canceled {
-			return nil, errors.New("could not get next")
+	}
+	for key, service := range ew.known {
+		if _, ok := known[key]; !ok {
+			ew.pending = append(ew.pending, &micro.Result{Action: "delete", Service: service})
+		}
+	}
+	ew.known = known
+	ew.rev = rev
+	ew.stats.update(func(h *WatchHealth) {
+		h.Resyncs++
+		h.Revision = rev
+	})
+	return nil
+}
+
+func sameService(a, b *micro.Service) bool {
+	ha, err := hash.Hash(a, hash.FormatV2, nil)
+	if err != nil {
+		return false
+	}
+	hb, err := hash.Hash(b, hash.FormatV2, nil)
+	if err != nil {
+		return false
+	}
+	return ha == hb
+}
+
+// apply 处理一批事件, 更新已发送节点与revision
+func (ew *etcdWatcher) apply(res clientv3.WatchResponse) {
+	for _, ev := range res.Events {
+		if !scoped(ew.root, ev.Kv.Key) {
+			continue
 		}
-		for _, ev := range res.Events {
-			service := decode(ev.Kv.Value)
-			var action string
-
-			switch ev.Type {
-			case clientv3.EventTypePut:
-				if ev.IsCreate() {
-					action = "create"
-				} else if ev.IsModify() {
-					action = "update"
-				}
-			case clientv3.EventTypeDelete:
-				action = "delete"
-
-				// get service from prevKv
+		key := string(ev.Kv.Key)
+		var service *micro.Service
+		var action string
+
+		switch ev.Type {
+		case clientv3.EventTypePut:
+			service = decode(ev.Kv.Value)
+			if service == nil {
+				continue
+			}
+			action = "update"
+			if _, ok := ew.known[key]; !ok {
+				action = "create"
+			}
+			ew.known[key] = service
+		case clientv3.EventTypeDelete:
+			// get service from known nodes or prevKv
+			service = ew.known[key]
+			if service == nil && ev.PrevKv != nil {
 				service = decode(ev.PrevKv.Value)
 			}
-
+			delete(ew.known, key)
 			if service == nil {
 				continue
 			}
-			return &micro.Result{
-				Action:  action,
-				Service: service,
-			}, nil
+			action = "delete"
+		}
+		ew.pending = append(ew.pending, &micro.Result{
+			Action:  action,
+			Service: service,
+		})
+	}
+	if res.Header.Revision > ew.rev {
+		ew.rev = res.Header.Revision
+	}
+	rev := ew.rev
+	ew.stats.update(func(h *WatchHealth) { h.Revision = rev })
+}
+
+func (ew *etcdWatcher) stopped() bool {
+	select {
+	case <-ew.ctx.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+// recover watch异常后恢复, 连续失败超过上限返回错误
+func (ew *etcdWatcher) recover(attempts int, cause error) error {
+	ew.stats.failed(cause)
+	if attempts > maxResumes {
+		return cause
+	}
+	log.Warnf(context.Background(), "etcd watch %s interrupted at revision %d: %v", ew.path, ew.rev, cause)
+	select {
+	case <-ew.ctx.Done():
+		return errors.New("could not get next")
+	case <-time.After(utils.BackoffDelay(attempts)):
+	}
+	if errors.Is(cause, errCompacted) {
+		if err := ew.resync(); err != nil {
+			ew.stats.failed(err)
+			return err
+		}
+	} else {
+		ew.stats.update(func(h *WatchHealth) { h.Resumes++ })
+	}
+	ew.watch()
+	return nil
+}
+
+var errCompacted = errors.New("watch revision compacted")
+
+func (ew *etcdWatcher) Next() (*micro.Result, error) {
+	var attempts int
+	for len(ew.pending) == 0 {
+		if ew.stopped() {
+			return nil, errors.New("could not get next")
+		}
+		res, ok := <-ew.w
+		var cause error
+		switch {
+		case !ok:
+			cause = errors.New("watch channel closed")
+		case res.CompactRevision != 0:
+			cause = errCompacted
+		case res.Canceled || res.Err() != nil:
+			cause = res.Err()
+			if cause == nil {
+				cause = errors.New("watch canceled")
+			}
+		default:
+			attempts = 0
+			ew.apply(res)
+			continue
+		}
+		if ew.stopped() {
+			return nil, errors.New("could not get next")
+		}
+		attempts++
+		if err := ew.recover(attempts, cause); err != nil {
+			return nil, err
 		}
 	}
-	return nil, errors.New("could not get next")
+	result := ew.pending[0]
+	ew.pending = ew.pending[1:]
+	return result, nil
 }
 
 func (ew *etcdWatcher) Stop() {
@@ -82,5 +300,6 @@ func (ew *etcdWatcher) Stop() {
 		return
 	default:
 		close(ew.stop)
+		ew.stats.update(func(h *WatchHealth) { h.Watchers-- })
 	}
 }
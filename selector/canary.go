@@ -0,0 +1,236 @@
+package selector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/config"
+	"github.com/lolizeppelin/micro/log"
+	"github.com/lolizeppelin/micro/utils"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"math/rand"
+	"path"
+	"strings"
+	"sync"
+)
+
+const (
+	// CanaryKey 灰度规则在配置中心的路径, 完整key为 CanaryKey + 服务名
+	CanaryKey = "selector/canary/"
+)
+
+// CanaryRoute 版本流量权重
+type CanaryRoute struct {
+	Version string `json:"version"` // 版本匹配 e.g 2 / 2.3 / 2.3.x / 2.3.1
+	Weight  int    `json:"weight"`  // 权重
+}
+
+// CanaryRule 服务灰度规则
+type CanaryRule struct {
+	Service string        `json:"service"`
+	Routes  []CanaryRoute `json:"routes"`
+}
+
+// pick 按权重选择路由
+func (r *CanaryRule) pick() (CanaryRoute, bool) {
+	total := 0
+	for _, route := range r.Routes {
+		if route.Weight > 0 {
+			total += route.Weight
+		}
+	}
+	if total <= 0 {
+		return CanaryRoute{}, false
+	}
+	n := rand.Intn(total)
+	for _, route := range r.Routes {
+		if route.Weight <= 0 {
+			continue
+		}
+		if n < route.Weight {
+			return route, true
+		}
+		n -= route.Weight
+	}
+	return CanaryRoute{}, false
+}
+
+/*
+MatchVersion 版本匹配
+pattern按"."分割逐段比较, 缺省段或"x"/"*"匹配任意值
+e.g 2.3 / 2.3.x 匹配 2.3.0 与 2.3.9, 2 匹配全部2.x.x
+*/
+func MatchVersion(pattern string, version micro.Version) bool {
+	pattern = strings.TrimPrefix(strings.TrimSpace(pattern), "v")
+	if pattern == "" {
+		return true
+	}
+	parts := strings.Split(pattern, ".")
+	if len(parts) > 3 {
+		return false
+	}
+	values := []int{version.Major, version.Minor, version.Patch}
+	for i, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			continue
+		}
+		v, err := utils.StringToInt(part)
+		if err != nil || v != values[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// VersionFilter 仅保留版本匹配的节点
+func VersionFilter(pattern string) Filter {
+	return func(services []*micro.Service) ([]*micro.Service, error) {
+		return filterVersion(services, pattern), nil
+	}
+}
+
+func filterVersion(services []*micro.Service, pattern string) []*micro.Service {
+	var matched []*micro.Service
+	for _, s := range services {
+		service := &micro.Service{
+			Name:      s.Name,
+			Version:   s.Version,
+			Metadata:  s.Metadata,
+			Endpoints: s.Endpoints,
+		}
+		for _, node := range s.Nodes {
+			if MatchVersion(pattern, node.Version) {
+				service.Nodes = append(service.Nodes, node)
+			}
+		}
+		if len(service.Nodes) > 0 {
+			matched = append(matched, service)
+		}
+	}
+	return matched
+}
+
+/*
+Canary 按版本权重分流
+1. 规则按服务名存放于配置中心 CanaryKey + 服务名
+2. 每次选择按权重选取一个版本, 无匹配节点时不做过滤
+3. 在请求版本兼容过滤之后执行, 选中版本的节点均不兼容请求版本时同样不过滤, 不会因分流导致无可用节点
+*/
+type Canary struct {
+	sync.RWMutex
+	rules map[string]*CanaryRule
+}
+
+func NewCanary(rules ...*CanaryRule) *Canary {
+	c := &Canary{
+		rules: map[string]*CanaryRule{},
+	}
+	for _, rule := range rules {
+		c.Store(rule)
+	}
+	return c
+}
+
+// Store 保存规则, 无路由时删除
+func (c *Canary) Store(rule *CanaryRule) {
+	c.Lock()
+	defer c.Unlock()
+	if len(rule.Routes) == 0 {
+		delete(c.rules, rule.Service)
+		return
+	}
+	c.rules[rule.Service] = rule
+}
+
+func (c *Canary) Delete(service string) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.rules, service)
+}
+
+func (c *Canary) Rule(service string) (*CanaryRule, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	rule, ok := c.rules[service]
+	return rule, ok
+}
+
+// Filter 灰度过滤器
+func (c *Canary) Filter() Filter {
+	return func(services []*micro.Service) ([]*micro.Service, error) {
+		if len(services) == 0 {
+			return services, nil
+		}
+		rule, ok := c.Rule(services[0].Name)
+		if !ok {
+			return services, nil
+		}
+		route, ok := rule.pick()
+		if !ok {
+			return services, nil
+		}
+		matched := filterVersion(services, route.Version)
+		if len(matched) == 0 {
+			return services, nil
+		}
+		return matched, nil
+	}
+}
+
+func decodeCanary(key string, value []byte) (*CanaryRule, error) {
+	rule := new(CanaryRule)
+	if err := json.Unmarshal(value, rule); err != nil {
+		return nil, err
+	}
+	rule.Service = path.Base(key)
+	return rule, nil
+}
+
+// Load 从配置中心加载全部规则
+func (c *Canary) Load(ctx context.Context, cfg *config.EtcdConfig) error {
+	kvs, err := cfg.List(ctx, CanaryKey)
+	if err != nil {
+		if errors.Is(err, micro.ErrConfigFound) {
+			return nil
+		}
+		return err
+	}
+	for _, kv := range kvs {
+		rule, e := decodeCanary(string(kv.Key), kv.Value)
+		if e != nil {
+			log.Errorf(ctx, "decode canary rule %s failed: %s", kv.Key, e.Error())
+			continue
+		}
+		c.Store(rule)
+	}
+	return nil
+}
+
+// Watch 加载规则并通过配置中心监听变更
+func (c *Canary) Watch(ctx context.Context, cfg *config.EtcdConfig) error {
+	if err := c.Load(ctx, cfg); err != nil {
+		return err
+	}
+	cfg.Watch(ctx, CanaryKey, func(ctx context.Context, _ string, events []*clientv3.Event, err error) {
+		if err != nil {
+			log.Errorf(ctx, "canary rule watcher failed: %s", err.Error())
+			return
+		}
+		for _, ev := range events {
+			key := string(ev.Kv.Key)
+			switch ev.Type {
+			case clientv3.EventTypePut:
+				rule, e := decodeCanary(key, ev.Kv.Value)
+				if e != nil {
+					log.Errorf(ctx, "decode canary rule %s failed: %s", key, e.Error())
+					continue
+				}
+				c.Store(rule)
+			case clientv3.EventTypeDelete:
+				c.Delete(path.Base(key))
+			}
+		}
+	})
+	return nil
+}
@@ -0,0 +1,43 @@
+package selector
+
+import (
+	"github.com/lolizeppelin/micro"
+	"testing"
+)
+
+func TestMatchVersion(t *testing.T) {
+	v := micro.Version{Major: 2, Minor: 3, Patch: 1}
+	for pattern, expect := range map[string]bool{
+		"2": true, "2.3": true, "2.3.x": true, "v2.3.1": true, "2.*.1": true,
+		"2.4": false, "2.3.2": false, "3": false, "2.3.1.0": false,
+	} {
+		if MatchVersion(pattern, v) != expect {
+			t.Errorf("match %s expect %v", pattern, expect)
+		}
+	}
+}
+
+func TestCanary(t *testing.T) {
+	c := NewCanary(&CanaryRule{
+		Service: "order",
+		Routes:  []CanaryRoute{{Version: "2.3.x", Weight: 95}, {Version: "2.4.x", Weight: 5}},
+	})
+	services := []*micro.Service{{
+		Name: "order",
+		Nodes: []*micro.Node{
+			{Id: "001", Version: micro.Version{Major: 2, Minor: 3}},
+			{Id: "002", Version: micro.Version{Major: 2, Minor: 4}},
+		},
+	}}
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		matched, _ := c.Filter()(services)
+		if len(matched) != 1 || len(matched[0].Nodes) != 1 {
+			t.Fatalf("canary filter failed: %v", matched)
+		}
+		counts[matched[0].Nodes[0].Id]++
+	}
+	if counts["002"] == 0 || counts["002"] > counts["001"] {
+		t.Fatalf("canary weight not applied: %v", counts)
+	}
+}
@@ -17,7 +17,17 @@ type registrySelector struct {
 }
 
 func (c *registrySelector) newCache(ttl time.Duration) cache.Cache {
-	return cache.New(c.so.Registry, ttl)
+	var opts []cache.Option
+	if c.so.Snapshot != "" {
+		opts = append(opts, cache.WithSnapshot(c.so.Snapshot))
+		if c.so.MaxStale > 0 {
+			opts = append(opts, cache.WithMaxStale(c.so.MaxStale))
+		}
+	}
+	if c.so.Keyring != nil {
+		opts = append(opts, cache.WithKeyring(c.so.Keyring))
+	}
+	return cache.New(c.so.Registry, ttl, opts...)
 }
 
 func (c *registrySelector) Name() string {
@@ -45,6 +55,25 @@ func (c *registrySelector) Select(service string, filters ...Filter) (Next, erro
 		}
 	}
 
+	// canary and locality filters run last, after version and endpoint filters,
+	// node status and source priority filters run after all others,
+	// all of them are skipped for an explicitly pinned node
+	if !Pinned(services) {
+		var defaults []Filter
+		if c.so.Canary != nil {
+			defaults = append(defaults, c.so.Canary.Filter())
+		}
+		if c.so.Locality != nil {
+			defaults = append(defaults, c.so.Locality.Filter())
+		}
+		for _, filter := range append(defaults, Available, Priority) {
+			services, err = filter(services)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	// if there's nothing left, return
 	if len(services) == 0 {
 		return nil, micro.ErrSelectEndpointNotFound
@@ -53,7 +82,10 @@ func (c *registrySelector) Select(service string, filters ...Filter) (Next, erro
 	return c.so.Strategy(services), nil
 }
 
-func (c *registrySelector) Mark(string, *micro.Node, error) {
+func (c *registrySelector) Mark(_ string, node *micro.Node, err error) {
+	if c.so.Locality != nil {
+		c.so.Locality.Mark(node, err)
+	}
 }
 
 func (c *registrySelector) Reset(string) {
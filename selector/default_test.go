@@ -0,0 +1,73 @@
+package selector
+
+import (
+	"context"
+	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/registry"
+	"testing"
+	"time"
+)
+
+func quarantineService(id, address string) *micro.Service {
+	return &micro.Service{
+		Name:      "lobby",
+		Version:   1,
+		Endpoints: map[string]*micro.Endpoint{"User.get": {Name: "User.get"}},
+		Nodes:     []*micro.Node{{Id: id, Address: address}},
+	}
+}
+
+func TestQuarantineRoundTrip(t *testing.T) {
+	r := registry.NewMemoryRegistry()
+	ctx := context.Background()
+	quarantined := quarantineService("a", "127.0.0.1:1")
+	quarantined.Nodes[0].Metadata = map[string]string{micro.MetadataStatus: micro.StatusQuarantined}
+	_ = r.Register(ctx, quarantined)
+	_ = r.Register(ctx, quarantineService("b", "127.0.0.1:2"))
+
+	s, _ := NewSelector(WithRegistry(r), WithStrategy(RoundRobin))
+	defer s.Close()
+	selected := func(filters ...Filter) map[string]bool {
+		ids := make(map[string]bool)
+		n, err := s.Select("lobby", filters...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 4; i++ {
+			node, err := n()
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids[node.Id] = true
+		}
+		return ids
+	}
+	if ids := selected(); ids["a"] || !ids["b"] {
+		t.Fatalf("quarantined node selected: %v", ids)
+	}
+
+	// 限定节点时可访问隔离节点(e.g 通过Admin组件解除隔离)
+	pin := func(services []*micro.Service) ([]*micro.Service, error) {
+		for _, service := range services {
+			for _, node := range service.Nodes {
+				if node.Id == "a" {
+					return []*micro.Service{Pin(service, node)}, nil
+				}
+			}
+		}
+		return nil, nil
+	}
+	if ids := selected(pin); !ids["a"] || len(ids) != 1 {
+		t.Fatalf("pinned quarantined node not selected: %v", ids)
+	}
+
+	// 解除隔离后重新参与选择
+	_ = r.Register(ctx, quarantineService("a", "127.0.0.1:1"))
+	deadline := time.Now().Add(time.Second)
+	for !selected()["a"] {
+		if time.Now().After(deadline) {
+			t.Fatal("node not selectable after unquarantine")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,341 @@
+package selector
+
+import (
+	"fmt"
+	"github.com/lolizeppelin/micro"
+	"strings"
+)
+
+type Operator string
+
+const (
+	OpEquals       Operator = "="
+	OpNotEquals    Operator = "!="
+	OpIn           Operator = "in"
+	OpNotIn        Operator = "notin"
+	OpExists       Operator = "exists"
+	OpDoesNotExist Operator = "!"
+)
+
+// Requirement 单个标签条件
+type Requirement struct {
+	Key      string
+	Operator Operator
+	Values   []string
+}
+
+func (r Requirement) Matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+	switch r.Operator {
+	case OpExists:
+		return ok
+	case OpDoesNotExist:
+		return !ok
+	case OpEquals, OpIn:
+		if !ok {
+			return false
+		}
+		for _, v := range r.Values {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	case OpNotEquals, OpNotIn:
+		if !ok {
+			return true
+		}
+		for _, v := range r.Values {
+			if v == value {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (r Requirement) String() string {
+	switch r.Operator {
+	case OpExists:
+		return r.Key
+	case OpDoesNotExist:
+		return "!" + r.Key
+	case OpIn, OpNotIn:
+		return fmt.Sprintf("%s %s (%s)", r.Key, r.Operator, strings.Join(r.Values, ","))
+	}
+	return fmt.Sprintf("%s%s%s", r.Key, r.Operator, r.Values[0])
+}
+
+/*
+LabelSelector 标签选择表达式(兼容kubernetes label selector语法), 条件之间为且关系
+支持 key=value key==value key!=value key in (a,b) key notin (a,b) key !key
+e.g zone in (a,b),tier!=batch,gpu
+*/
+type LabelSelector []Requirement
+
+// Matches 节点元数据覆盖服务元数据后匹配
+func (s LabelSelector) Matches(labels map[string]string) bool {
+	for _, r := range s {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s LabelSelector) String() string {
+	parts := make([]string, len(s))
+	for i, r := range s {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// Filter 转换为节点过滤器
+func (s LabelSelector) Filter() Filter {
+	return func(services []*micro.Service) ([]*micro.Service, error) {
+		var matched []*micro.Service
+		for _, srv := range services {
+			service := &micro.Service{
+				Name:      srv.Name,
+				Version:   srv.Version,
+				Metadata:  srv.Metadata,
+				Endpoints: srv.Endpoints,
+			}
+			for _, node := range srv.Nodes {
+				labels := make(map[string]string, len(srv.Metadata)+len(node.Metadata))
+				for k, v := range srv.Metadata {
+					labels[k] = v
+				}
+				for k, v := range node.Metadata {
+					labels[k] = v
+				}
+				if s.Matches(labels) {
+					service.Nodes = append(service.Nodes, node)
+				}
+			}
+			if len(service.Nodes) > 0 {
+				matched = append(matched, service)
+			}
+		}
+		return matched, nil
+	}
+}
+
+// LabelFilter 解析表达式并转换为节点过滤器
+func LabelFilter(expr string) (Filter, error) {
+	s, err := ParseLabelSelector(expr)
+	if err != nil {
+		return nil, err
+	}
+	return s.Filter(), nil
+}
+
+// ParseError 表达式解析错误
+type ParseError struct {
+	Expr     string
+	Position int
+	Reason   string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("label selector %q: %s at position %d", e.Expr, e.Reason, e.Position)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdentifier
+	tokenComma
+	tokenOpen
+	tokenClose
+	tokenEquals
+	tokenDoubleEquals
+	tokenNotEquals
+	tokenNot
+)
+
+type token struct {
+	kind     tokenKind
+	value    string
+	position int
+}
+
+func (t token) describe() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of expression"
+	case tokenIdentifier:
+		return fmt.Sprintf("%q", t.value)
+	}
+	return fmt.Sprintf("'%s'", t.value)
+}
+
+func isLabelChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c == '-' || c == '_' || c == '.' || c == '/'
+}
+
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == ',':
+			tokens = append(tokens, token{tokenComma, ",", i})
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokenOpen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokenClose, ")", i})
+			i++
+		case c == '=':
+			if i+1 < len(expr) && expr[i+1] == '=' {
+				tokens = append(tokens, token{tokenDoubleEquals, "==", i})
+				i += 2
+			} else {
+				tokens = append(tokens, token{tokenEquals, "=", i})
+				i++
+			}
+		case c == '!':
+			if i+1 < len(expr) && expr[i+1] == '=' {
+				tokens = append(tokens, token{tokenNotEquals, "!=", i})
+				i += 2
+			} else {
+				tokens = append(tokens, token{tokenNot, "!", i})
+				i++
+			}
+		case isLabelChar(c):
+			start := i
+			for i < len(expr) && isLabelChar(expr[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdentifier, expr[start:i], start})
+		default:
+			return nil, &ParseError{Expr: expr, Position: i, Reason: fmt.Sprintf("unexpected character '%c'", c)}
+		}
+	}
+	return append(tokens, token{tokenEOF, "", len(expr)}), nil
+}
+
+type labelParser struct {
+	expr   string
+	tokens []token
+	pos    int
+}
+
+func (p *labelParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *labelParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *labelParser) fail(t token, format string, args ...any) error {
+	return &ParseError{Expr: p.expr, Position: t.position, Reason: fmt.Sprintf(format, args...)}
+}
+
+func (p *labelParser) requirement() (Requirement, error) {
+	t := p.next()
+	if t.kind == tokenNot {
+		key := p.next()
+		if key.kind != tokenIdentifier {
+			return Requirement{}, p.fail(key, "expected label key after '!', got %s", key.describe())
+		}
+		return Requirement{Key: key.value, Operator: OpDoesNotExist}, nil
+	}
+	if t.kind != tokenIdentifier {
+		return Requirement{}, p.fail(t, "expected label key, got %s", t.describe())
+	}
+	r := Requirement{Key: t.value}
+
+	op := p.peek()
+	switch {
+	case op.kind == tokenComma || op.kind == tokenEOF:
+		r.Operator = OpExists
+		return r, nil
+	case op.kind == tokenEquals || op.kind == tokenDoubleEquals || op.kind == tokenNotEquals:
+		p.next()
+		r.Operator = OpEquals
+		if op.kind == tokenNotEquals {
+			r.Operator = OpNotEquals
+		}
+		value := p.next()
+		if value.kind != tokenIdentifier {
+			return Requirement{}, p.fail(value, "expected value after %s, got %s", op.describe(), value.describe())
+		}
+		r.Values = []string{value.value}
+		return r, nil
+	case op.kind == tokenIdentifier && (op.value == string(OpIn) || op.value == string(OpNotIn)):
+		p.next()
+		r.Operator = Operator(op.value)
+		values, err := p.values()
+		if err != nil {
+			return Requirement{}, err
+		}
+		r.Values = values
+		return r, nil
+	}
+	return Requirement{}, p.fail(op, "expected operator after key %q, got %s", r.Key, op.describe())
+}
+
+func (p *labelParser) values() ([]string, error) {
+	open := p.next()
+	if open.kind != tokenOpen {
+		return nil, p.fail(open, "expected '(', got %s", open.describe())
+	}
+	var values []string
+	for {
+		t := p.next()
+		if t.kind != tokenIdentifier {
+			return nil, p.fail(t, "expected value, got %s", t.describe())
+		}
+		values = append(values, t.value)
+		t = p.next()
+		switch t.kind {
+		case tokenComma:
+			continue
+		case tokenClose:
+			return values, nil
+		}
+		return nil, p.fail(t, "expected ',' or ')', got %s", t.describe())
+	}
+}
+
+// ParseLabelSelector 解析标签选择表达式
+func ParseLabelSelector(expr string) (LabelSelector, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &labelParser{expr: expr, tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, p.fail(p.peek(), "empty expression")
+	}
+	var s LabelSelector
+	for {
+		var r Requirement
+		if r, err = p.requirement(); err != nil {
+			return nil, err
+		}
+		s = append(s, r)
+		t := p.next()
+		switch t.kind {
+		case tokenEOF:
+			return s, nil
+		case tokenComma:
+			continue
+		}
+		return nil, p.fail(t, "expected ',' or end of expression, got %s", t.describe())
+	}
+}
@@ -0,0 +1,50 @@
+package selector
+
+import (
+	"github.com/lolizeppelin/micro"
+	"testing"
+)
+
+func TestParseLabelSelector(t *testing.T) {
+	s, err := ParseLabelSelector("zone in (a,b),tier!=batch,gpu,!legacy,region==cn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(s.String())
+
+	labels := map[string]string{"zone": "a", "tier": "web", "gpu": "", "region": "cn"}
+	if !s.Matches(labels) {
+		t.Fatalf("labels %v should match %s", labels, s)
+	}
+	labels["tier"] = "batch"
+	if s.Matches(labels) {
+		t.Fatalf("labels %v should not match %s", labels, s)
+	}
+
+	for _, expr := range []string{"", "zone in a", "zone in (a,", "tier!=", "zone=a,,", "zone ~ a", "zone=a b"} {
+		_, err = ParseLabelSelector(expr)
+		if err == nil {
+			t.Fatalf("expression %q should fail", expr)
+		}
+		t.Log(err)
+	}
+}
+
+func TestLabelFilter(t *testing.T) {
+	filter, err := LabelFilter("zone=a,gpu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	services := []*micro.Service{{
+		Name:     "lobby",
+		Metadata: map[string]string{"gpu": "true"},
+		Nodes: []*micro.Node{
+			{Id: "001", Metadata: map[string]string{"zone": "a"}},
+			{Id: "002", Metadata: map[string]string{"zone": "b"}},
+		},
+	}}
+	matched, _ := filter(services)
+	if len(matched) != 1 || len(matched[0].Nodes) != 1 || matched[0].Nodes[0].Id != "001" {
+		t.Fatalf("label filter failed: %v", matched)
+	}
+}
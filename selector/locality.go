@@ -0,0 +1,186 @@
+package selector
+
+import (
+	"context"
+	"github.com/lolizeppelin/micro"
+	exc "github.com/lolizeppelin/micro/errors"
+	"github.com/lolizeppelin/micro/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	LocalityScope = "micro/selector/locality"
+
+	// DefaultLocalityThreshold 本可用区健康节点占比低于该值时允许跨区
+	DefaultLocalityThreshold = 0.5
+	// DefaultLocalityCooldown 节点调用失败后被视为不健康的时长
+	DefaultLocalityCooldown = 30 * time.Second
+)
+
+var (
+	_version, _ = micro.NewVersion("1.0.0")
+)
+
+/*
+Locality 可用区优先路由
+1. 优先选择与调用方相同可用区(region相同时比较zone)的节点
+2. 本可用区健康节点占比低于阈值时退化为全部节点(跨区)
+3. 节点健康状态由Selector.Mark反馈
+*/
+type Locality struct {
+	topology  micro.Topology
+	threshold float64
+	cooldown  time.Duration
+
+	sync.RWMutex
+	unhealthy map[string]time.Time // 节点不健康截止时间
+
+	local    int64
+	cross    int64
+	requests metric.Int64Counter
+}
+
+// NewLocality 创建可用区优先路由, topology为调用方所在拓扑
+func NewLocality(topology micro.Topology, threshold float64, cooldown ...time.Duration) *Locality {
+	if threshold <= 0 || threshold > 1 {
+		threshold = DefaultLocalityThreshold
+	}
+	l := &Locality{
+		topology:  topology,
+		threshold: threshold,
+		cooldown:  DefaultLocalityCooldown,
+		unhealthy: map[string]time.Time{},
+	}
+	if len(cooldown) > 0 && cooldown[0] > 0 {
+		l.cooldown = cooldown[0]
+	}
+
+	meter := tracing.GetMeter(LocalityScope, _version)
+	l.requests, _ = meter.Int64Counter("selector.locality.requests",
+		metric.WithDescription("requests routed by locality"))
+	_, _ = meter.Float64ObservableGauge("selector.locality.cross.ratio",
+		metric.WithDescription("share of cross-zone requests"),
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			o.Observe(l.CrossRatio(), metric.WithAttributes(attribute.String("zone", l.topology.Zone)))
+			return nil
+		}))
+	return l
+}
+
+// Topology 调用方拓扑
+func (l *Locality) Topology() micro.Topology {
+	return l.topology
+}
+
+// CrossRatio 跨区请求占比
+func (l *Locality) CrossRatio() float64 {
+	local := atomic.LoadInt64(&l.local)
+	cross := atomic.LoadInt64(&l.cross)
+	if local+cross == 0 {
+		return 0
+	}
+	return float64(cross) / float64(local+cross)
+}
+
+// Local 节点是否与调用方处于同一可用区
+func (l *Locality) Local(node *micro.Node) bool {
+	t := micro.NodeTopology(node)
+	if l.topology.Region != "" && t.Region != "" && l.topology.Region != t.Region {
+		return false
+	}
+	return t.Zone == l.topology.Zone
+}
+
+func (l *Locality) healthy(node *micro.Node, now time.Time) bool {
+	l.RLock()
+	until, ok := l.unhealthy[node.Id]
+	l.RUnlock()
+	return !ok || now.After(until)
+}
+
+// Filter 可用区过滤器
+func (l *Locality) Filter() Filter {
+	return func(services []*micro.Service) ([]*micro.Service, error) {
+		if l.topology.Zone == "" {
+			return services, nil
+		}
+		now := time.Now()
+		var total, healthy int
+		var local []*micro.Service
+		for _, s := range services {
+			service := &micro.Service{
+				Name:      s.Name,
+				Version:   s.Version,
+				Metadata:  s.Metadata,
+				Endpoints: s.Endpoints,
+			}
+			for _, node := range s.Nodes {
+				if !l.Local(node) {
+					continue
+				}
+				total++
+				if l.healthy(node, now) {
+					healthy++
+					service.Nodes = append(service.Nodes, node)
+				}
+			}
+			if len(service.Nodes) > 0 {
+				local = append(local, service)
+			}
+		}
+		// 本可用区无节点或健康占比不足, 跨区
+		if total == 0 || float64(healthy)/float64(total) < l.threshold {
+			return services, nil
+		}
+		return local, nil
+	}
+}
+
+// Mark 记录节点调用结果与跨区流量
+func (l *Locality) Mark(node *micro.Node, err error) {
+	if node == nil {
+		return
+	}
+	locality := "local"
+	if l.Local(node) {
+		atomic.AddInt64(&l.local, 1)
+	} else {
+		locality = "cross"
+		atomic.AddInt64(&l.cross, 1)
+	}
+	if l.requests != nil {
+		l.requests.Add(context.Background(), 1, metric.WithAttributes(
+			attribute.String("zone", l.topology.Zone),
+			attribute.String("locality", locality),
+		))
+	}
+
+	now := time.Now()
+	l.Lock()
+	defer l.Unlock()
+	l.prune(now)
+	if err != nil && unavailable(err) {
+		l.unhealthy[node.Id] = now.Add(l.cooldown)
+		return
+	}
+	delete(l.unhealthy, node.Id)
+}
+
+// prune 清理已过冷却期的节点, 已下线节点不会再被Mark, 需在此清理, 需持有锁
+func (l *Locality) prune(now time.Time) {
+	for id, until := range l.unhealthy {
+		if now.After(until) {
+			delete(l.unhealthy, id)
+		}
+	}
+}
+
+// unavailable 连接失败或服务端5xx错误视为节点不健康
+func unavailable(err error) bool {
+	e := exc.FromError(err)
+	return e.Code == 0 || e.Code >= 500
+}
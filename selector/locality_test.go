@@ -0,0 +1,59 @@
+package selector
+
+import (
+	"fmt"
+	"github.com/lolizeppelin/micro"
+	"testing"
+	"time"
+)
+
+func zoneNode(id, zone string) *micro.Node {
+	return &micro.Node{Id: id, Metadata: map[string]string{micro.MetadataZone: zone}}
+}
+
+func TestLocality(t *testing.T) {
+	l := NewLocality(micro.Topology{Zone: "a"}, 0.5)
+	services := []*micro.Service{{
+		Name: "lobby",
+		Nodes: []*micro.Node{
+			zoneNode("001", "a"), zoneNode("002", "a"), zoneNode("003", "b"),
+		},
+	}}
+
+	matched, _ := l.Filter()(services)
+	if len(matched) != 1 || len(matched[0].Nodes) != 2 {
+		t.Fatalf("local nodes not preferred: %v", matched)
+	}
+
+	// 本可用区半数节点故障, 仍满足阈值
+	l.Mark(services[0].Nodes[0], fmt.Errorf("connection refused"))
+	matched, _ = l.Filter()(services)
+	if len(matched[0].Nodes) != 1 || matched[0].Nodes[0].Id != "002" {
+		t.Fatalf("unhealthy node not removed: %v", matched[0].Nodes)
+	}
+
+	// 本可用区全部故障, 跨区
+	l.Mark(services[0].Nodes[1], fmt.Errorf("connection refused"))
+	matched, _ = l.Filter()(services)
+	if len(matched[0].Nodes) != 3 {
+		t.Fatalf("cross zone failover failed: %v", matched[0].Nodes)
+	}
+
+	// 2次本区, 1次跨区
+	l.Mark(services[0].Nodes[2], nil)
+	if ratio := l.CrossRatio(); ratio < 0.33 || ratio > 0.34 {
+		t.Fatalf("expect cross ratio 1/3, got %.2f", ratio)
+	}
+}
+
+func TestLocalityPrune(t *testing.T) {
+	l := NewLocality(micro.Topology{Zone: "a"}, 0.5, time.Millisecond)
+	l.Mark(zoneNode("gone", "a"), fmt.Errorf("connection refused"))
+	time.Sleep(5 * time.Millisecond)
+	l.Mark(zoneNode("001", "a"), nil)
+	l.RLock()
+	defer l.RUnlock()
+	if _, ok := l.unhealthy["gone"]; ok {
+		t.Fatal("expired unhealthy node not pruned")
+	}
+}
@@ -2,6 +2,7 @@ package selector
 
 import (
 	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/registry"
 	"time"
 )
 
@@ -9,6 +10,11 @@ type Options struct {
 	Registry micro.Registry
 	Strategy Strategy
 	TTL      time.Duration
+	Locality *Locality
+	Canary   *Canary
+	Snapshot string            // 注册中心缓存快照文件
+	MaxStale time.Duration     // 快照最大有效时长
+	Keyring  *registry.Keyring // 验证注册记录签名
 }
 
 type Option func(*Options)
@@ -33,3 +39,32 @@ func WithCacheSeconds(seconds int32) Option {
 		o.TTL = time.Second * time.Duration(seconds)
 	}
 }
+
+// WithLocality sets the locality used to prefer nodes in the caller's zone.
+func WithLocality(l *Locality) Option {
+	return func(o *Options) {
+		o.Locality = l
+	}
+}
+
+// WithCanary sets the weighted version routing rules.
+func WithCanary(c *Canary) Option {
+	return func(o *Options) {
+		o.Canary = c
+	}
+}
+
+// WithSnapshot persists the registry cache to path, used on cold start when the registry is unreachable.
+func WithSnapshot(path string, maxStale time.Duration) Option {
+	return func(o *Options) {
+		o.Snapshot = path
+		o.MaxStale = maxStale
+	}
+}
+
+// WithKeyring drops registry records that are unsigned or fail to verify.
+func WithKeyring(k *registry.Keyring) Option {
+	return func(o *Options) {
+		o.Keyring = k
+	}
+}
@@ -3,6 +3,7 @@ package selector
 import (
 	"github.com/lolizeppelin/micro"
 	"github.com/minio/highwayhash"
+	"math"
 	"math/rand"
 	"strings"
 	"sync"
@@ -62,18 +63,42 @@ func ScoreNodes(keys []string, services []*micro.Service) (possibleNodes []*micr
 		for _, n := range s.Nodes {
 			// Use the base key from above to calculate a derivative 64 bit hash number based off the instance ID.
 			score := highwayhash.Sum64([]byte(n.Id), key[:])
-			scores = append(scores, score)
+			scores = append(scores, weighted(score, Weight(n)))
 			possibleNodes = append(possibleNodes, n)
 		}
 	}
 	return
 }
 
+/*
+weighted 加权rendezvous hash, score = -weight/ln(hash/2^64)
+1. 权重相同时与原始hash顺序一致
+2. 正浮点数的位表示与数值单调一致, 直接作为uint64分值比较
+*/
+func weighted(score uint64, weight int) uint64 {
+	if weight <= 0 {
+		return 0
+	}
+	u := math.Min(float64(score)/math.MaxUint64, math.Nextafter(1, 0))
+	if u <= 0 {
+		u = math.SmallestNonzeroFloat64
+	}
+	w := -float64(weight) / float64(micro.DefaultWeight) / math.Log(u)
+	return math.Float64bits(w)
+}
+
+// Random 按节点权重随机选择
 func Random(services []*micro.Service) Next {
 	nodes := make([]*micro.Node, 0, len(services))
+	var total int
 
 	for _, service := range services {
-		nodes = append(nodes, service.Nodes...)
+		for _, node := range service.Nodes {
+			if weight := Weight(node); weight > 0 {
+				nodes = append(nodes, node)
+				total += weight
+			}
+		}
 	}
 
 	return func() (*micro.Node, error) {
@@ -81,20 +106,52 @@ func Random(services []*micro.Service) Next {
 			return nil, micro.ErrNoneServiceAvailable
 		}
 
-		i := rand.Int() % len(nodes)
-		return nodes[i], nil
+		n := rand.Intn(total)
+		for _, node := range nodes {
+			n -= Weight(node)
+			if n < 0 {
+				return node, nil
+			}
+		}
+		return nodes[len(nodes)-1], nil
 	}
 }
 
-// RoundRobin is a roundrobin strategy algorithm for node selection
+// RoundRobin is a smooth weighted roundrobin strategy algorithm for node selection
 func RoundRobin(services []*micro.Service) Next {
 	nodes := make([]*micro.Node, 0, len(services))
+	var weights []int
+	var total int
 
 	for _, service := range services {
-		nodes = append(nodes, service.Nodes...)
+		for _, node := range service.Nodes {
+			if weight := Weight(node); weight > 0 {
+				nodes = append(nodes, node)
+				weights = append(weights, weight)
+				total += weight
+			}
+		}
 	}
 
-	var i = rand.Int()
+	current := make([]int, len(nodes))
+	// step 平滑加权轮询, 每轮current之和保持为0
+	step := func() int {
+		best := 0
+		for i, weight := range weights {
+			current[i] += weight
+			if current[i] > current[best] {
+				best = i
+			}
+		}
+		current[best] -= total
+		return best
+	}
+	// 随机预先执行若干轮, 避免所有调用方从同一节点开始且不影响权重分布
+	if len(nodes) > 0 {
+		for i := rand.Intn(len(nodes)); i > 0; i-- {
+			step()
+		}
+	}
 	var mtx sync.Mutex
 
 	return func() (*micro.Node, error) {
@@ -103,10 +160,9 @@ func RoundRobin(services []*micro.Service) Next {
 		}
 
 		mtx.Lock()
-		node := nodes[i%len(nodes)]
-		i++
+		best := step()
 		mtx.Unlock()
 
-		return node, nil
+		return nodes[best], nil
 	}
 }
@@ -0,0 +1,128 @@
+package selector
+
+import (
+	"github.com/lolizeppelin/micro"
+	"strconv"
+)
+
+// Weight 节点权重, 未设置或格式错误返回默认权重, 负数视为0
+func Weight(node *micro.Node) int {
+	if node == nil || node.Metadata == nil {
+		return micro.DefaultWeight
+	}
+	v, ok := node.Metadata[micro.MetadataWeight]
+	if !ok {
+		return micro.DefaultWeight
+	}
+	weight, err := strconv.Atoi(v)
+	if err != nil {
+		return micro.DefaultWeight
+	}
+	if weight < 0 {
+		return 0
+	}
+	return weight
+}
+
+/*
+Available 节点状态过滤器
+1. 隔离(quarantined)与权重为0的节点不分配流量
+2. 下线中(draining)的节点仅在没有其他可用节点时分配流量
+*/
+func Available(services []*micro.Service) ([]*micro.Service, error) {
+	var active, draining []*micro.Service
+	for _, s := range services {
+		var nodes, drains []*micro.Node
+		for _, node := range s.Nodes {
+			if Weight(node) == 0 {
+				continue
+			}
+			switch node.Metadata[micro.MetadataStatus] {
+			case micro.StatusQuarantined:
+			case micro.StatusDraining:
+				drains = append(drains, node)
+			default:
+				nodes = append(nodes, node)
+			}
+		}
+		if len(nodes) > 0 {
+			active = append(active, withNodes(s, nodes))
+		}
+		if len(drains) > 0 {
+			draining = append(draining, withNodes(s, drains))
+		}
+	}
+	if len(active) > 0 {
+		return active, nil
+	}
+	return draining, nil
+}
+
+// priority 节点来源优先级, 未设置为0
+func priority(node *micro.Node) int {
+	p, err := strconv.Atoi(node.Metadata[micro.MetadataPriority])
+	if err != nil {
+		return 0
+	}
+	return p
+}
+
+// Priority 仅保留优先级最高的节点, 本地集群无可用节点时切换到下一优先级, 需在Available之后执行
+func Priority(services []*micro.Service) ([]*micro.Service, error) {
+	var best int
+	var found bool
+	for _, s := range services {
+		for _, node := range s.Nodes {
+			if p := priority(node); !found || p < best {
+				best, found = p, true
+			}
+		}
+	}
+	var filtered []*micro.Service
+	for _, s := range services {
+		var nodes []*micro.Node
+		for _, node := range s.Nodes {
+			if priority(node) == best {
+				nodes = append(nodes, node)
+			}
+		}
+		if len(nodes) > 0 {
+			filtered = append(filtered, withNodes(s, nodes))
+		}
+	}
+	return filtered, nil
+}
+
+// pinnedKey 服务元数据中标记显式限定节点, 仅存在于选择过程中的副本
+const pinnedKey = "selector.pinned"
+
+// Pin 标记为显式限定的节点(e.g client.WithNode), 跳过金丝雀/就近/状态/优先级过滤, 以便访问隔离中的节点
+func Pin(s *micro.Service, node *micro.Node) *micro.Service {
+	md := make(map[string]string, len(s.Metadata)+1)
+	for k, v := range s.Metadata {
+		md[k] = v
+	}
+	md[pinnedKey] = "true"
+	return &micro.Service{
+		Name:      s.Name,
+		Version:   s.Version,
+		Metadata:  md,
+		Endpoints: s.Endpoints,
+		Nodes:     []*micro.Node{node},
+	}
+}
+
+// Pinned 选择结果是否为显式限定的节点
+func Pinned(services []*micro.Service) bool {
+	return len(services) == 1 && services[0].Metadata[pinnedKey] == "true"
+}
+
+func withNodes(s *micro.Service, nodes []*micro.Node) *micro.Service {
+	return &micro.Service{
+		Name:      s.Name,
+		Version:   s.Version,
+		Metadata:  s.Metadata,
+		Endpoints: s.Endpoints,
+		Nodes:     nodes,
+	}
+}
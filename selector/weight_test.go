@@ -0,0 +1,59 @@
+package selector
+
+import (
+	"github.com/lolizeppelin/micro"
+	"testing"
+)
+
+func weightNode(id string, md map[string]string) *micro.Node {
+	return &micro.Node{Id: id, Metadata: md}
+}
+
+func TestAvailable(t *testing.T) {
+	services := []*micro.Service{{Name: "lobby", Nodes: []*micro.Node{
+		weightNode("a", map[string]string{micro.MetadataStatus: micro.StatusDraining}),
+		weightNode("b", map[string]string{micro.MetadataStatus: micro.StatusQuarantined}),
+		weightNode("c", map[string]string{micro.MetadataWeight: "0"}),
+		weightNode("d", nil),
+	}}}
+	available, _ := Available(services)
+	if len(available) != 1 || len(available[0].Nodes) != 1 || available[0].Nodes[0].Id != "d" {
+		t.Fatalf("expect only active node d, got %v", available)
+	}
+	// 无其他可用节点时使用下线中节点
+	services[0].Nodes = services[0].Nodes[:3]
+	available, _ = Available(services)
+	if len(available) != 1 || available[0].Nodes[0].Id != "a" {
+		t.Fatalf("expect draining node a, got %v", available)
+	}
+}
+
+func TestWeightedRoundRobin(t *testing.T) {
+	services := []*micro.Service{{Name: "lobby", Nodes: []*micro.Node{
+		weightNode("a", map[string]string{micro.MetadataWeight: "300"}),
+		weightNode("b", nil),
+	}}}
+	next := RoundRobin(services)
+	counts := map[string]int{}
+	for i := 0; i < 400; i++ {
+		node, _ := next()
+		counts[node.Id]++
+	}
+	if counts["a"] != 300 || counts["b"] != 100 {
+		t.Fatalf("unexpected weighted distribution %v", counts)
+	}
+}
+
+func TestWeightedScore(t *testing.T) {
+	// 相同权重时保持原始hash顺序
+	if weighted(1<<40, micro.DefaultWeight) >= weighted(1<<50, micro.DefaultWeight) {
+		t.Fatal("weighted score should keep hash order")
+	}
+	if weighted(1<<40, 0) != 0 {
+		t.Fatal("zero weight should score zero")
+	}
+	// 高权重提升分值
+	if weighted(1<<40, 10*micro.DefaultWeight) <= weighted(1<<40, micro.DefaultWeight) {
+		t.Fatal("higher weight should score higher")
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"github.com/lolizeppelin/micro"
+	exc "github.com/lolizeppelin/micro/errors"
+	"github.com/lolizeppelin/micro/log"
+	"maps"
+)
+
+// reserved 框架写入的元数据, 不允许运行时修改
+var reserved = map[string]bool{
+	"registry":              true,
+	"broker":                true,
+	"protocol":              true,
+	micro.MetadataSchema:    true,
+	micro.MetadataSignature: true,
+}
+
+// Metadata returns a copy of the node metadata.
+func (g *RPCServer) Metadata() map[string]string {
+	g.RLock()
+	defer g.RUnlock()
+	return maps.Clone(g.service.registry.Nodes[0].Metadata)
+}
+
+/*
+UpdateMetadata 更新节点元数据并立即推送到注册中心
+1. 空值删除对应key
+2. 节点信息按写时复制替换, 避免与注册并发读取冲突
+*/
+func (g *RPCServer) UpdateMetadata(ctx context.Context, md map[string]string) (map[string]string, error) {
+	for k := range md {
+		if reserved[k] {
+			return nil, exc.BadRequest("micro.server.metadata", "metadata %s is reserved", k)
+		}
+	}
+	g.Lock()
+	current := g.service.registry
+	node := *current.Nodes[0]
+	node.Metadata = maps.Clone(node.Metadata)
+	for k, v := range md {
+		if v == "" {
+			delete(node.Metadata, k)
+		} else {
+			node.Metadata[k] = v
+		}
+	}
+	service := *current
+	service.Nodes = []*micro.Node{&node}
+	g.service.registry = &service
+	registered := g.registered
+	g.Unlock()
+
+	log.Infof(ctx, "Server %s node %s metadata updated: %v", service.Name, node.Id, md)
+	// 未注册时在启动注册时一并写入
+	if registered {
+		if err := g.Register(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return maps.Clone(node.Metadata), nil
+}
+
+/*
+Admin 内置管理组件, 通过WithAdmin开启
+调用方通过 client.WithNode 指定节点id调用 micro.AdminMetadata 更新节点权重/状态/标签
+*/
+type Admin struct {
+	micro.ComponentBase
+	server *RPCServer
+}
+
+// AdminQuery admin接口无query参数
+type AdminQuery struct{}
+
+func (*Admin) Name() string {
+	return "Admin"
+}
+
+func (*Admin) Collection() string {
+	return "Admins"
+}
+
+// RPC_Metadata 更新节点元数据, 返回更新后的元数据
+func (a *Admin) RPC_Metadata(ctx context.Context, _ *AdminQuery, req *micro.NodeMetadata) (*micro.NodeMetadata, error) {
+	a.server.RLock()
+	id := a.server.service.registry.Nodes[0].Id
+	a.server.RUnlock()
+	if req.Node != "" && req.Node != id {
+		return nil, exc.BadRequest("micro.server.metadata", "node %s not match %s", req.Node, id)
+	}
+	md, err := a.server.UpdateMetadata(ctx, req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	return &micro.NodeMetadata{Node: id, Metadata: md}, nil
+}
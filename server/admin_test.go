@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/client"
+	"github.com/lolizeppelin/micro/registry"
+	"net"
+	"testing"
+)
+
+type adminTest struct {
+	micro.ComponentBase
+}
+
+func (*adminTest) Name() string       { return "Test" }
+func (*adminTest) Collection() string { return "Tests" }
+
+func TestAdminMetadata(t *testing.T) {
+	ls, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ls.Close()
+	version, _ := micro.NewVersion("1.0.0")
+	r := registry.NewMemoryRegistry()
+	opts := NewOptions("lobby")
+	opts.Version = version
+	opts.Listener = ls
+	opts.Registry = r
+	opts.Metadata = map[string]string{"zone": "a"}
+	WithAdmin()(opts)
+	WithComponents(&adminTest{})(opts)
+	srv := newGRPCServer(opts)
+	if srv.service.Handler("Admin", "metadata") == nil {
+		t.Fatal("admin handler not registered")
+	}
+	ctx := context.Background()
+	if err = srv.Register(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	admin := &Admin{server: srv}
+	id := srv.service.registry.Nodes[0].Id
+	if _, err = admin.RPC_Metadata(ctx, nil, &micro.NodeMetadata{Node: "other"}); err == nil {
+		t.Fatal("expect node mismatch error")
+	}
+	res, err := admin.RPC_Metadata(ctx, nil, &micro.NodeMetadata{Node: id, Metadata: map[string]string{
+		micro.MetadataStatus: micro.StatusDraining, "zone": "",
+	}})
+	if err != nil || res.Metadata[micro.MetadataStatus] != micro.StatusDraining || res.Metadata["zone"] != "" {
+		t.Fatalf("unexpected metadata %v %v", res, err)
+	}
+	services, _ := r.GetService("lobby")
+	if services[0].Nodes[0].Metadata[micro.MetadataStatus] != micro.StatusDraining {
+		t.Fatal("metadata not pushed to registry")
+	}
+	if _, err = srv.UpdateMetadata(ctx, map[string]string{"registry": "x"}); err == nil {
+		t.Fatal("expect reserved metadata rejected")
+	}
+}
+
+func TestAdminMetadataClient(t *testing.T) {
+	ls, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ls.Close()
+	version, _ := micro.NewVersion("1.0.0")
+	r := registry.NewMemoryRegistry()
+	opts := NewOptions("lobby")
+	opts.Version = version
+	opts.Listener = ls
+	opts.Registry = r
+	opts.Metadata = map[string]string{}
+	WithAdmin()(opts)
+	WithComponents(&adminTest{})(opts)
+	srv := newGRPCServer(opts)
+	go func() { _ = srv.server.Serve(ls) }()
+	defer srv.server.Stop()
+	ctx := context.Background()
+	if err = srv.Register(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// 隔离节点仍可通过Admin组件解除隔离
+	if _, err = srv.UpdateMetadata(ctx, map[string]string{micro.MetadataStatus: micro.StatusQuarantined}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := client.NewClient(client.NewOptions(client.Registry(r)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := srv.service.registry.Nodes[0].Id
+	md, err := client.UpdateNodeMetadata(ctx, c, "lobby", id, map[string]string{micro.MetadataStatus: ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := md[micro.MetadataStatus]; ok {
+		t.Fatalf("quarantine not cleared: %v", md)
+	}
+}
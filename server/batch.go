@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/lolizeppelin/micro"
+	exc "github.com/lolizeppelin/micro/errors"
+	"github.com/lolizeppelin/micro/transport"
+	tp "github.com/lolizeppelin/micro/transport/grpc/proto"
+	"sync"
+)
+
+/*
+processBatch 批量请求
+1. 子请求继承批量请求头, 经processRequest按普通请求分发(限流/幂等/BuildArgs)
+2. 并发数受BatchConcurrency限制, 结果与错误按子请求顺序返回
+*/
+func (g *RPCServer) processBatch(ctx context.Context, request, response *tp.Message) error {
+	items, err := transport.DecodeBatch(request.Body)
+	if err != nil {
+		return exc.BadRequest("go.micro.server", "decode batch request failed: %v", err)
+	}
+
+	limit := g.opts.BatchConcurrency
+	if limit <= 0 || limit > len(items) {
+		limit = len(items)
+	}
+	results := make([]*transport.Message, len(items))
+	slots := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, item *transport.Message) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			results[i] = g.processItem(ctx, request.Header, i, item)
+		}(i, item)
+	}
+	wg.Wait()
+
+	response.Body, err = transport.EncodeBatch(results)
+	return err
+}
+
+func (g *RPCServer) processItem(ctx context.Context, headers map[string]string, i int, item *transport.Message) *transport.Message {
+	header := make(map[string]string, len(headers)+len(item.Header))
+	for k, v := range headers {
+		header[k] = v
+	}
+	delete(header, transport.Batch)
+	for k, v := range item.Header {
+		header[k] = v
+	}
+	// 同一批量请求内的子请求使用不同的幂等key
+	if key, ok := header[micro.IdempotencyKey]; ok && key != "" {
+		header[micro.IdempotencyKey] = fmt.Sprintf("%s#%d", key, i)
+	}
+
+	request := &tp.Message{
+		Header: header,
+		Query:  item.Query.Encode(),
+		Body:   item.Body,
+	}
+	response := new(tp.Message)
+	if err := g.processRequest(ctx, request, response); err != nil {
+		e, ok := exc.ClientError("go.micro.server", err).(*exc.Error)
+		if !ok {
+			e = exc.NewError("go.micro.server", err.Error(), 500)
+		}
+		return &transport.Message{Header: map[string]string{transport.Error: e.Error()}}
+	}
+	return &transport.Message{Body: response.Body}
+}
@@ -6,17 +6,21 @@ import (
 	"fmt"
 	"github.com/lolizeppelin/micro"
 	exc "github.com/lolizeppelin/micro/errors"
+	"github.com/lolizeppelin/micro/idempotency"
+	"github.com/lolizeppelin/micro/limiter"
 	"github.com/lolizeppelin/micro/log"
 	"github.com/lolizeppelin/micro/tracing"
 	"github.com/lolizeppelin/micro/transport"
 	tp "github.com/lolizeppelin/micro/transport/grpc/proto"
 	"go.opentelemetry.io/otel/attribute"
 	oteltrace "go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/encoding"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"math"
 	"reflect"
 	"runtime/debug"
 	"strconv"
@@ -37,8 +41,13 @@ func (g *RPCServer) handler(ctx context.Context, msg *tp.Message) (*tp.Message,
 	statusCode := codes.OK
 	statusDesc := ""
 
+	var err error
 	response := new(tp.Message)
-	err := g.processRequest(ctx, msg, response)
+	if _, ok := msg.Header[transport.Batch]; ok {
+		err = g.processBatch(ctx, msg, response)
+	} else {
+		err = g.processRequest(ctx, msg, response)
+	}
 	if err != nil {
 		var errStatus *status.Status
 		var vErr *exc.Error
@@ -75,12 +84,16 @@ func (g *RPCServer) processRequest(ctx context.Context, request, response *tp.Me
 		),
 	)
 
+	var complete func() // 保存幂等结果, 需在panic恢复后执行
 	defer func() {
 		if r := recover(); r != nil {
 			span.AddEvent("panic")
 			log.Errorf(ctx, "panic recovered: %v, stack: %s", r, string(debug.Stack()))
 			err = exc.InternalServerError("go.micro.server", "panic recovered: %v", r)
 		}
+		if complete != nil {
+			complete()
+		}
 
 		span.End()
 	}()
@@ -134,6 +147,71 @@ func (g *RPCServer) processRequest(ctx context.Context, request, response *tp.Me
 		return status.New(codes.Unimplemented, "unknown service or method").Err()
 	}
 
+	if g.opts.RateLimit != nil {
+		if err = g.opts.RateLimit.Allow(ctx, endpoint, handler.Metadata); err != nil {
+			span.AddEvent("errors", oteltrace.WithAttributes(attribute.String("limiter", "rate")))
+			if retry, ok := exc.RetryAfter(err); ok {
+				_ = grpc.SetTrailer(ctx, metadata.Pairs(strings.ToLower(micro.RetryAfter),
+					strconv.FormatInt(int64(retry.Seconds()), 10)))
+			}
+			return err
+		}
+	}
+
+	if g.opts.Bulkheads != nil {
+		if bulkhead := g.opts.Bulkheads.Get(serviceName, endpoint); bulkhead != nil {
+			release, allowed := bulkhead.Acquire(ctx)
+			if !allowed {
+				span.AddEvent("errors", oteltrace.WithAttributes(attribute.String("limiter", "bulkhead")))
+				if ctx.Err() != nil {
+					return exc.Timeout("go.micro.server", "bulkhead wait timeout")
+				}
+				return exc.ServiceUnavailable("go.micro.server", "bulkhead queue full")
+			}
+			defer release()
+		}
+	}
+
+	if g.opts.Limiter != nil {
+		l := g.opts.Limiter.Get(endpoint)
+		release, allowed := l.Acquire()
+		if !allowed {
+			span.AddEvent("errors", oteltrace.WithAttributes(attribute.String("limiter", "rejected")))
+			retry := int64(math.Ceil(l.RetryAfter().Seconds()))
+			_ = grpc.SetTrailer(ctx, metadata.Pairs(strings.ToLower(micro.RetryAfter), strconv.FormatInt(retry, 10)))
+			return exc.ServiceUnavailable("go.micro.server", "concurrency limit exceeded; retry-after=%d", retry)
+		}
+		start := time.Now()
+		defer func() {
+			release(time.Since(start), limiter.Dropped(err) || ctx.Err() != nil)
+		}()
+	}
+
+	if _, _, method := handler.UrlPath(); g.opts.Idempotency != nil && !idempotency.Idempotent(method) {
+		if key, found := transport.ContextGet(ctx, micro.IdempotencyKey); found && key != "" {
+			key = idempotency.Scope(ctx, endpoint, key)
+			result, first, e := g.opts.Idempotency.Acquire(ctx, key, g.opts.IdempotentTTL)
+			switch {
+			case e != nil:
+				log.Warnf(ctx, "idempotency key %s acquire failed: %v", key, e)
+			case !first:
+				span.AddEvent("idempotent", oteltrace.WithAttributes(attribute.String("key", key)))
+				if result.Error != nil {
+					return result.Error
+				}
+				response.Body = result.Body
+				return nil
+			default:
+				complete = func() {
+					if e = g.opts.Idempotency.Complete(context.Background(), key,
+						idempotency.NewResult(response.Body, err), g.opts.IdempotentTTL); e != nil {
+						log.Warnf(ctx, "idempotency key %s complete failed: %v", key, e)
+					}
+				}
+			}
+		}
+	}
+
 	protocol, ok := request.Header[micro.ContentType]
 	accept, ok := request.Header[micro.Accept]
 
@@ -3,6 +3,7 @@ package server
 import (
 	"fmt"
 	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/limiter"
 	"github.com/lolizeppelin/micro/utils"
 	"github.com/lolizeppelin/micro/utils/jsonschema"
 	"github.com/xeipuuv/gojsonschema"
@@ -139,6 +140,11 @@ func ExtractComponent(component micro.Component) (map[string]*Handler, []*Handle
 
 	rtype := typ.Elem()
 
+	var limits map[string]string
+	if rl, ok := component.(micro.RateLimited); ok {
+		limits = rl.RateLimits()
+	}
+
 	for m := 0; m < typ.NumMethod(); m++ {
 		method := typ.Method(m)
 		if !isHandlerMethod(method) {
@@ -189,6 +195,12 @@ func ExtractComponent(component micro.Component) (map[string]*Handler, []*Handle
 				metadata["res"] = "json"
 			}
 		}
+		if spec, ok := limits[method.Name]; ok {
+			if _, err := limiter.ParseRule(spec); err != nil {
+				panic(fmt.Sprintf("rate limit of %s.%s error: %v", component.Name(), method.Name, err))
+			}
+			metadata[limiter.MetadataRateLimit] = spec
+		}
 		handlers = append(handlers, handler)
 
 		handler.Metadata = metadata
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/lolizeppelin/micro/log"
+	"github.com/lolizeppelin/micro/registry"
 	tp "github.com/lolizeppelin/micro/transport/grpc/proto"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
@@ -22,8 +23,9 @@ type RPCServer struct {
 	tp.UnimplementedTransportServer
 
 	sync.RWMutex
-	wg   *sync.WaitGroup
-	exit chan chan error
+	wg      *sync.WaitGroup
+	exit    chan chan error
+	refresh chan struct{} // 立即推送注册信息
 
 	started    bool
 	registered bool
@@ -41,9 +43,13 @@ func newGRPCServer(opts *Options) *RPCServer {
 	srv := &RPCServer{
 		opts:    opts,
 		exit:    make(chan chan error),
-		service: newService(opts),
+		refresh: make(chan struct{}, 1),
 		wg:      opts.WaitGroup,
 	}
+	if opts.Admin {
+		opts.Components = append(opts.Components, &Admin{server: srv})
+	}
+	srv.service = newService(opts)
 	// configure the grpc server
 
 	_opts := []grpc.ServerOption{
@@ -89,6 +95,11 @@ func (g *RPCServer) Start() error {
 		}
 	}()
 
+	done := make(chan struct{})
+	if watcher, ok := config.Registry.(registry.LeaseWatcher); ok {
+		go g.leases(ctx, watcher, done)
+	}
+
 	go func() {
 		t := time.NewTicker(g.opts.Interval)
 
@@ -120,7 +131,11 @@ func (g *RPCServer) Start() error {
 						config.Name, config.Id, checkErr.Error())
 					continue
 				}
-				// Register 内部包含续租
+				// 续租由注册中心续租流完成, 此处仅推送变更
+				if err = g.Register(ctx); err != nil {
+					log.Errorf(ctx, "Server register error: %s", err.Error())
+				}
+			case <-g.refresh:
 				if err = g.Register(ctx); err != nil {
 					log.Errorf(ctx, "Server register error: %s", err.Error())
 				}
@@ -130,6 +145,7 @@ func (g *RPCServer) Start() error {
 				break Loop
 			}
 		}
+		close(done)
 
 		// deregister self
 		if err = g.Deregister(ctx); err != nil {
@@ -4,6 +4,8 @@ import (
 	"context"
 	"github.com/lolizeppelin/micro"
 	"github.com/lolizeppelin/micro/broker"
+	"github.com/lolizeppelin/micro/idempotency"
+	"github.com/lolizeppelin/micro/limiter"
 	"github.com/lolizeppelin/micro/registry"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
@@ -14,22 +16,33 @@ import (
 )
 
 type Options struct {
-	Id            uint64
-	Name          string
-	MaxMsgSize    int
-	Version       *micro.Version // 当前服务版本号
-	Min           *micro.Version // 支持的最小版本(默认当前版本)
-	Max           *micro.Version // 支持的最大版本(默认当前版本)
-	Interval      time.Duration
-	Listener      net.Listener
-	Broker        broker.Broker
-	Registry      micro.Registry
-	Components    []micro.Component
-	GrpcOpts      []grpc.ServerOption
-	BrokerOpts    []broker.SubscribeOption
-	RegisterCheck func(context.Context) error
-	WaitGroup     *sync.WaitGroup
-	Metadata      map[string]string
+	Id                 uint64
+	Name               string
+	MaxMsgSize         int
+	Version            *micro.Version    // 当前服务版本号
+	Min                *micro.Version    // 支持的最小版本(默认当前版本)
+	Max                *micro.Version    // 支持的最大版本(默认当前版本)
+	Compatibility      *micro.Constraint // 兼容的请求版本约束, 设置后替代Min/Max
+	Interval           time.Duration
+	Listener           net.Listener
+	Broker             broker.Broker
+	Registry           micro.Registry
+	Components         []micro.Component
+	GrpcOpts           []grpc.ServerOption
+	BrokerOpts         []broker.SubscribeOption
+	RegisterCheck      func(context.Context) error
+	LeaseHandler       func(context.Context, *registry.LeaseEvent) // 注册租约丢失/恢复回调
+	Admin              bool                                        // 开启内置管理组件
+	Schema             bool                                        // 发布endpoint结构到注册中心
+	CompatibilityCheck bool                                        // 注册前检查与运行中相同主版本的结构兼容性
+	WaitGroup          *sync.WaitGroup
+	Metadata           map[string]string
+	Limiter            *limiter.Group     // endpoint并发限制
+	Bulkheads          *limiter.Bulkheads // 组件/endpoint舱壁隔离
+	BatchConcurrency   int                // 批量请求子项并发上限, 0不限制
+	RateLimit          *limiter.RateLimit // endpoint令牌桶限流
+	Idempotency        idempotency.Store  // 幂等结果存储
+	IdempotentTTL      time.Duration      // 幂等结果保存时长
 
 	Credentials credentials.TransportCredentials
 }
@@ -79,6 +92,17 @@ func WithMin(version *micro.Version) Option {
 	}
 }
 
+// WithCompatibility 兼容的请求版本约束表达式 e.g ">=2.3 <3"
+func WithCompatibility(expr string) Option {
+	constraint, err := micro.NewConstraint(expr)
+	if err != nil {
+		panic(err.Error())
+	}
+	return func(o *Options) {
+		o.Compatibility = constraint
+	}
+}
+
 func WithMaxMsgSize(size int) Option {
 	if size <= 1024 {
 		panic("grpc buff size error")
@@ -133,6 +157,34 @@ func WithRegisterCheck(f func(context.Context) error) Option {
 	}
 }
 
+// WithLeaseHandler sets the callback invoked on registry lease lost/restored events.
+func WithLeaseHandler(f func(context.Context, *registry.LeaseEvent)) Option {
+	return func(o *Options) {
+		o.LeaseHandler = f
+	}
+}
+
+// WithAdmin enables the built-in Admin component used to update node metadata remotely.
+func WithAdmin() Option {
+	return func(o *Options) {
+		o.Admin = true
+	}
+}
+
+// WithSchema publishes endpoint request/query/response schemas into the registry.
+func WithSchema() Option {
+	return func(o *Options) {
+		o.Schema = true
+	}
+}
+
+// WithCompatibilityCheck refuses to register when endpoint schemas break the running version with the same major.
+func WithCompatibilityCheck() Option {
+	return func(o *Options) {
+		o.CompatibilityCheck = true
+	}
+}
+
 func WithWaitGroup(wg *sync.WaitGroup) Option {
 	return func(o *Options) {
 		o.WaitGroup = wg
@@ -146,6 +198,59 @@ func WithMetadata(md map[string]string) Option {
 	}
 }
 
+// WithTopology 通过Metadata发布节点拓扑标签(region/zone/host), 需在WithMetadata之后调用
+func WithTopology(topology micro.Topology) Option {
+	return func(o *Options) {
+		if o.Metadata == nil {
+			o.Metadata = map[string]string{}
+		}
+		topology.Metadata(o.Metadata)
+	}
+}
+
+// WithConcurrencyLimit 按endpoint启用自适应并发限制, 超限请求返回503
+func WithConcurrencyLimit(opts ...limiter.Option) Option {
+	return func(o *Options) {
+		o.Limiter = limiter.NewGroup("server", opts...)
+	}
+}
+
+/*
+WithRateLimit 启用endpoint令牌桶限流, 超限请求返回429
+rules为配置的限流规则(优先于组件声明), "*"匹配所有endpoint
+distributed为分布式令牌桶存储(e.g limiter.NewEtcdStore), 可为nil
+*/
+func WithRateLimit(distributed limiter.Store, rules map[string]*limiter.Rule) Option {
+	return func(o *Options) {
+		o.RateLimit = limiter.NewRateLimit(distributed, rules)
+	}
+}
+
+// WithBulkhead 为组件(e.g User)或endpoint(e.g User.get)配置舱壁, 最大并发concurrency, 排队上限queue, 队列满时返回503
+func WithBulkhead(name string, concurrency, queue int) Option {
+	return func(o *Options) {
+		if o.Bulkheads == nil {
+			o.Bulkheads = limiter.NewBulkheads()
+		}
+		o.Bulkheads.Add(name, concurrency, queue)
+	}
+}
+
+// WithBatchConcurrency 限制批量请求中子请求的并发执行数
+func WithBatchConcurrency(n int) Option {
+	return func(o *Options) {
+		o.BatchConcurrency = n
+	}
+}
+
+// WithIdempotency 按Idempotency-Key头去重非幂等方法(POST/PATCH及网关接口)请求, 重复请求重放首次执行结果, ttl<=0时使用默认时长
+func WithIdempotency(store idempotency.Store, ttl time.Duration) Option {
+	return func(o *Options) {
+		o.Idempotency = store
+		o.IdempotentTTL = ttl
+	}
+}
+
 // WithCredentials 设置证书
 func WithCredentials(credentials credentials.TransportCredentials) Option {
 	return func(o *Options) {
@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"github.com/lolizeppelin/micro/log"
+	"github.com/lolizeppelin/micro/registry"
 	"github.com/lolizeppelin/micro/utils"
 	"time"
 )
@@ -11,13 +12,25 @@ func (g *RPCServer) Register(ctx context.Context) error {
 
 	g.RLock()
 	registered := g.registered
+	service := g.service.registry
 	g.RUnlock()
 
 	config := g.opts
-	service := g.service.registry
 
 	if !registered {
 		log.Infof(ctx, "Registry [%s] Registering node: %s", config.Registry.Name(), service.Name)
+		if err := g.checkCompatibility(ctx); err != nil {
+			log.Errorf(ctx, "Registry [%s] refuse incompatible node: %s", config.Registry.Name(), err.Error())
+			return err
+		}
+		if err := g.publishSchemas(ctx); err != nil {
+			log.Errorf(ctx, "Registry [%s] publish schemas failed: %s", config.Registry.Name(), err.Error())
+			return err
+		}
+	} else if _, ok := config.Registry.(registry.SchemaRegistry); ok { // 续约结构
+		if err := g.publishSchemas(ctx); err != nil {
+			log.Warnf(ctx, "Registry [%s] refresh schemas failed: %s", config.Registry.Name(), err.Error())
+		}
 	}
 
 	var err error
@@ -57,7 +70,9 @@ func (g *RPCServer) Register(ctx context.Context) error {
 func (g *RPCServer) Deregister(ctx context.Context) error {
 
 	if g.opts != nil && g.opts.Registry != nil {
+		g.RLock()
 		service := g.service.registry
+		g.RUnlock()
 		if err := g.opts.Registry.Deregister(ctx, service); err != nil {
 			return err
 		}
@@ -76,3 +91,29 @@ func (g *RPCServer) Deregister(ctx context.Context) error {
 	g.Unlock()
 	return nil
 }
+
+// Refresh pushes the registration to the registry immediately instead of waiting for the next tick.
+func (g *RPCServer) Refresh() {
+	select {
+	case g.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// leases 处理注册中心租约事件, 租约恢复后立即推送最新注册信息
+func (g *RPCServer) leases(ctx context.Context, watcher registry.LeaseWatcher, done chan struct{}) {
+	events := watcher.LeaseEvents()
+	for {
+		select {
+		case event := <-events:
+			if event.Action == registry.LeaseRestored {
+				g.Refresh()
+			}
+			if g.opts.LeaseHandler != nil {
+				g.opts.LeaseHandler(ctx, event)
+			}
+		case <-done:
+			return
+		}
+	}
+}
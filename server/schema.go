@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/log"
+	"github.com/lolizeppelin/micro/registry"
+	"github.com/lolizeppelin/micro/utils/jsonschema"
+	"reflect"
+)
+
+// endpointSchema 由注册的endpoint与handler生成endpoint结构
+func endpointSchema(ep *micro.Endpoint, handler *Handler) (*registry.EndpointSchema, error) {
+	endpoint := &registry.EndpointSchema{
+		Name:       ep.Name,
+		PrimaryKey: ep.PrimaryKey,
+		Internal:   ep.Internal,
+		Comment:    jsonschema.GetComment(reflect.PointerTo(handler.Rtype), handler.Method),
+	}
+	var err error
+	if handler.Query != nil {
+		if endpoint.Query, err = jsonschema.Schema(handler.Query, true); err != nil {
+			return nil, err
+		}
+	}
+	if handler.Request != nil {
+		body := &jsonschema.ContentBody{Type: handler.Metadata["req"]}
+		if body.Schema, err = jsonschema.Schema(handler.Request, false); err != nil {
+			return nil, err
+		}
+		endpoint.Request = body
+	}
+	if handler.Response != nil {
+		body := &jsonschema.ContentBody{Type: handler.Metadata["res"]}
+		if body.Schema, err = jsonschema.Schema(handler.Response, false); err != nil {
+			return nil, err
+		}
+		endpoint.Response = body
+	}
+	return endpoint, nil
+}
+
+// BuildSchemas builds the endpoint schemas of components without starting a server, e.g for offline compatibility checks.
+func BuildSchemas(name string, version *micro.Version, components ...micro.Component) (*registry.Schemas, error) {
+	services, _ := ExtractComponents(components)
+	return buildSchemas(name, version, services)
+}
+
+// buildSchemas 生成服务全部endpoint结构
+func buildSchemas(name string, version *micro.Version, services map[string]map[string]*Handler) (*registry.Schemas, error) {
+	schemas := &registry.Schemas{
+		Service:   name,
+		Version:   *version,
+		Endpoints: map[string]*registry.EndpointSchema{},
+	}
+	handlers := make(map[string]*Handler)
+	for service, methods := range services {
+		for method, handler := range methods {
+			handlers[service+"."+method] = handler
+		}
+	}
+	for _, ep := range extractEndpoints(services) {
+		endpoint, err := endpointSchema(ep, handlers[ep.Name])
+		if err != nil {
+			return nil, err
+		}
+		schemas.Endpoints[endpoint.Name] = endpoint
+	}
+	return schemas, nil
+}
+
+// publishSchemas 首次注册前保存endpoint结构, 之后每次注册时续约
+func (g *RPCServer) publishSchemas(ctx context.Context) error {
+	schemas := g.service.schemas
+	if schemas == nil {
+		return nil
+	}
+	r, ok := g.opts.Registry.(registry.SchemaRegistry)
+	if !ok {
+		log.Warnf(ctx, "Registry [%s] not support schemas", g.opts.Registry.Name())
+		return nil
+	}
+	_, err := r.PutSchema(ctx, schemas)
+	return err
+}
+
+// schemaMetadata 生成结构, 发布结构时写入节点元数据
+func schemaMetadata(opts *Options, services map[string]map[string]*Handler, node *micro.Node) *registry.Schemas {
+	schemas, err := buildSchemas(opts.Name, opts.Version, services)
+	if err != nil {
+		panic("build endpoint schemas failed: " + err.Error())
+	}
+	if !opts.Schema {
+		return schemas
+	}
+	hash, err := schemas.Hash()
+	if err != nil {
+		panic("hash endpoint schemas failed: " + err.Error())
+	}
+	node.Metadata[micro.MetadataSchema] = hash
+	return schemas
+}
+
+/*
+checkCompatibility 注册前检查与注册中心中相同主版本的最高版本结构是否兼容, 不兼容时拒绝注册
+注册中心中无相同主版本或未发布结构时跳过
+*/
+func (g *RPCServer) checkCompatibility(ctx context.Context) error {
+	local := g.service.schemas
+	r, ok := g.opts.Registry.(registry.SchemaRegistry)
+	if !g.opts.CompatibilityCheck || local == nil || !ok {
+		return nil
+	}
+	services, err := g.opts.Registry.GetService(g.opts.Name)
+	if err != nil {
+		if errors.Is(err, micro.ErrServiceNotFound) {
+			return nil
+		}
+		return err
+	}
+	var running *micro.Version
+	for _, service := range services {
+		for _, node := range service.Nodes {
+			if node.Version.Major != local.Version.Major || node.Metadata[micro.MetadataSchema] == "" {
+				continue
+			}
+			if running == nil || node.Version.Compare(*running, true) > 0 {
+				version := node.Version
+				running = &version
+			}
+		}
+	}
+	if running == nil {
+		return nil
+	}
+	old, err := r.GetSchema(g.opts.Name, running)
+	if err != nil {
+		if errors.Is(err, micro.ErrServiceNotFound) {
+			return nil
+		}
+		return err
+	}
+	changes := registry.Incompatible(old, local)
+	if len(changes) == 0 {
+		return nil
+	}
+	for _, change := range changes {
+		log.Errorf(ctx, "Server %s %s incompatible with %s: %s", g.opts.Name,
+			local.Version.Version(true), running.Version(true), change)
+	}
+	return fmt.Errorf("version %s has %d incompatible changes with running version %s",
+		local.Version.Version(true), len(changes), running.Version(true))
+}
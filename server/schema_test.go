@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"github.com/lolizeppelin/micro"
+	"github.com/lolizeppelin/micro/registry"
+	"net"
+	"testing"
+)
+
+type schemaQuery struct{}
+
+type schemaBody struct {
+	Name string `json:"name" required:"true"`
+}
+
+type SchemaTest struct {
+	micro.ComponentBase
+}
+
+func (*SchemaTest) Name() string       { return "Test" }
+func (*SchemaTest) Collection() string { return "Tests" }
+
+func (*SchemaTest) Echo(_ context.Context, _ *schemaQuery, body *schemaBody) (*schemaBody, error) {
+	return body, nil
+}
+
+func TestSchema(t *testing.T) {
+	ls, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ls.Close()
+	version, _ := micro.NewVersion("1.2.3")
+	r := registry.NewMemoryRegistry()
+	opts := NewOptions("lobby")
+	opts.Version = version
+	opts.Listener = ls
+	opts.Registry = r
+	opts.Metadata = map[string]string{}
+	WithSchema()(opts)
+	WithComponents(&SchemaTest{})(opts)
+	srv := newGRPCServer(opts)
+	if err = srv.Register(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	schemas, err := r.(registry.SchemaRegistry).GetSchema("lobby", version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint, ok := schemas.Endpoints["Test.echo"]
+	if !ok || endpoint.Request == nil || endpoint.Request.Schema["properties"] == nil {
+		t.Fatalf("unexpected schemas %+v", schemas.Endpoints)
+	}
+	if _, err = r.(registry.SchemaRegistry).GetSchema("lobby", &micro.Version{Major: 2}); err == nil {
+		t.Fatal("expect schema not found for other version")
+	}
+}
+
+type schemaBodyV2 struct {
+	Name string `json:"name" required:"true"`
+	Age  int    `json:"age" required:"true"`
+}
+
+type SchemaTestV2 struct {
+	SchemaTest
+}
+
+func (*SchemaTestV2) Echo(_ context.Context, _ *schemaQuery, body *schemaBodyV2) (*schemaBodyV2, error) {
+	return body, nil
+}
+
+func TestCompatibilityCheck(t *testing.T) {
+	r := registry.NewMemoryRegistry()
+	start := func(v string, component micro.Component) error {
+		ls, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ls.Close()
+		version, _ := micro.NewVersion(v)
+		opts := NewOptions("lobby")
+		opts.Version = version
+		opts.Listener = ls
+		opts.Registry = r
+		opts.Metadata = map[string]string{}
+		WithSchema()(opts)
+		WithCompatibilityCheck()(opts)
+		WithComponents(component)(opts)
+		return newGRPCServer(opts).Register(context.Background())
+	}
+	if err := start("1.0.0", &SchemaTest{}); err != nil {
+		t.Fatal(err)
+	}
+	// 新增必填字段不兼容
+	if err := start("1.1.0", &SchemaTestV2{}); err == nil {
+		t.Fatal("expect incompatible build refused")
+	}
+	// 新主版本不检查
+	if err := start("2.0.0", &SchemaTestV2{}); err != nil {
+		t.Fatal(err)
+	}
+
+	old, _ := BuildSchemas("lobby", &micro.Version{Major: 1}, &SchemaTest{})
+	latest, _ := BuildSchemas("lobby", &micro.Version{Major: 1, Minor: 1}, &SchemaTestV2{})
+	changes := registry.Incompatible(old, latest)
+	if len(changes) != 1 || changes[0].Path != "Test.echo.request:age" {
+		t.Fatalf("unexpected changes %v", changes)
+	}
+
+	// 旧版本无请求载荷, 新增必填载荷不兼容
+	old.Endpoints["Test.echo"].Request = nil
+	changes = registry.Incompatible(old, latest)
+	if len(changes) != 2 || changes[0].Path != "Test.echo.request:name" {
+		t.Fatalf("unexpected changes %v", changes)
+	}
+}
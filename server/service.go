@@ -3,6 +3,7 @@ package server
 import (
 	"github.com/lolizeppelin/micro"
 	"github.com/lolizeppelin/micro/broker"
+	"github.com/lolizeppelin/micro/registry"
 	"github.com/lolizeppelin/micro/utils"
 )
 
@@ -11,6 +12,7 @@ type Service struct {
 	services   map[string]map[string]*Handler
 	registry   *micro.Service
 	subscribed map[string]broker.Subscriber
+	schemas    *registry.Schemas // 发布到注册中心的endpoint结构
 }
 
 func (s *Service) Handler(service string, method string) *Handler {
@@ -27,12 +29,13 @@ func newService(opts *Options) *Service {
 	endpoints := extractEndpoints(services)
 
 	node := &micro.Node{
-		Id:       SNBase62(opts.Id),
-		Version:  *opts.Version, // 节点版本号
-		Max:      opts.Max,
-		Min:      opts.Min,
-		Address:  opts.Listener.Addr().String(),
-		Metadata: opts.Metadata,
+		Id:            SNBase62(opts.Id),
+		Version:       *opts.Version, // 节点版本号
+		Max:           opts.Max,
+		Min:           opts.Min,
+		Compatibility: opts.Compatibility,
+		Address:       opts.Listener.Addr().String(),
+		Metadata:      opts.Metadata,
 	}
 
 	node.Metadata["registry"] = opts.Registry.Name()
@@ -43,6 +46,11 @@ func newService(opts *Options) *Service {
 	//node.Metadata["transport"] = g.String()
 	node.Metadata["protocol"] = "grpc"
 
+	var schemas *registry.Schemas
+	if opts.Schema || opts.CompatibilityCheck {
+		schemas = schemaMetadata(opts, services, node)
+	}
+
 	emap, err := utils.SliceToMapByField[*micro.Endpoint, string](endpoints, "Name")
 	if err != nil {
 		panic("convert endpoint list to map failed")
@@ -53,6 +61,7 @@ func newService(opts *Options) *Service {
 		services: services,
 		//endpoints:  endpoints,
 		subscribed: map[string]broker.Subscriber{},
+		schemas:    schemas,
 		registry: &micro.Service{
 			Name:      opts.Name,
 			Version:   opts.Version.Major,
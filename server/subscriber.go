@@ -75,13 +75,17 @@ func (s *Service) dispatch(ctx context.Context, event broker.Event) (err error)
 
 	defer func() {
 		wg.Done()
-		if e := event.Ack(); e != nil {
-			log.Errorf(ctx, "brcker ack failed： %s", err.Error())
-		}
 		if r := recover(); r != nil {
 			log.Errorf(ctx, "panic recovered: \n%s", string(debug.Stack()))
 			err = exc.InternalServerError("go.micro.server", "panic recovered: %v", r)
 		}
+		// 处理失败不确认, 由broker重新投递
+		if err != nil {
+			return
+		}
+		if e := event.Ack(); e != nil {
+			log.Errorf(ctx, "brcker ack failed： %s", e.Error())
+		}
 	}()
 
 	hdr := make(map[string]string, len(msg.Header))
@@ -107,7 +111,7 @@ func (s *Service) SubscriberAll(ctx context.Context) error {
 		return nil
 	}
 
-	topics := registry.Topics(s.opts.Name, s.opts.Version, s.registry.Nodes[0].Id)
+	topics := registry.Topics(registry.Namespace(s.opts.Registry), s.opts.Name, s.opts.Version, s.registry.Nodes[0].Id)
 
 	for _, topic := range topics {
 		if _, ok := s.subscribed[topic]; ok {
@@ -0,0 +1,25 @@
+package transport
+
+import (
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+/*
+批量请求
+1. 请求Message带Batch头, Body为msgpack编码的子请求列表, 子请求头包含各自的Endpoint/Content-Type/Accept/PrimaryKey
+2. 返回Body为同序的子结果列表, 子请求失败时结果头Error为json编码的错误
+*/
+
+// EncodeBatch encodes batch items or results.
+func EncodeBatch(items []*Message) ([]byte, error) {
+	return msgpack.Marshal(items)
+}
+
+// DecodeBatch decodes batch items or results.
+func DecodeBatch(data []byte) ([]*Message, error) {
+	var items []*Message
+	if err := msgpack.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
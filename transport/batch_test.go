@@ -0,0 +1,25 @@
+package transport
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestBatch(t *testing.T) {
+	items := []*Message{
+		{Header: map[string]string{Endpoint: "User.get"}, Query: url.Values{"a": {"1"}}, Body: []byte("x")},
+		{Header: map[string]string{Error: "failed"}},
+	}
+	data, err := EncodeBatch(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeBatch(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 2 || decoded[0].Header[Endpoint] != "User.get" ||
+		decoded[0].Query.Get("a") != "1" || string(decoded[0].Body) != "x" || decoded[1].Header[Error] != "failed" {
+		t.Fatalf("batch decode not match: %+v", decoded)
+	}
+}
@@ -27,4 +27,6 @@ const (
 	TraceIDKey = "Micro-Trace-ID"
 	// Stream header.
 	Stream = "Micro-Stream"
+	// Batch header marks a message carrying batch items.
+	Batch = "Micro-Batch"
 )
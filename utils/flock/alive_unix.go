@@ -0,0 +1,17 @@
+//go:build !windows
+
+package flock
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Alive 本机进程是否存在
+func Alive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
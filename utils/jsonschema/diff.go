@@ -0,0 +1,150 @@
+package jsonschema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Change 不兼容变更
+type Change struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s", c.Path, c.Reason)
+}
+
+/*
+Diff 比较新旧jsonschema, 返回不兼容变更
+1. input为true按请求方向检查, 新结构需接受旧调用方发送的数据: 类型不可收窄, 不可新增必填字段, 禁止额外字段时不可删除字段
+2. input为false按返回方向检查, 旧调用方需能解析新返回: 类型不可扩大, 不可删除字段
+*/
+func Diff(old, latest map[string]any, input bool) []Change {
+	// 旧版本无请求参数时, 新增的必填参数同样不兼容
+	if old == nil && input {
+		old = map[string]any{}
+	}
+	var changes []Change
+	diff("", old, latest, input, &changes)
+	return changes
+}
+
+func diff(path string, old, latest map[string]any, input bool, changes *[]Change) {
+	if old == nil || latest == nil {
+		return
+	}
+	name := path
+	if name == "" {
+		name = "$"
+	}
+
+	oldTypes, newTypes := schemaTypes(old), schemaTypes(latest)
+	if len(oldTypes) > 0 && len(newTypes) > 0 {
+		narrowed := input && !subset(oldTypes, newTypes)
+		widened := !input && !subset(newTypes, oldTypes)
+		if narrowed || widened {
+			*changes = append(*changes, Change{Path: name, Reason: fmt.Sprintf("type changed from %s to %s",
+				strings.Join(oldTypes, "|"), strings.Join(newTypes, "|"))})
+		}
+	}
+
+	if input {
+		required := stringSet(old["required"])
+		for _, field := range stringList(latest["required"]) {
+			if !required[field] {
+				*changes = append(*changes, Change{Path: join(path, field), Reason: "new required field"})
+			}
+		}
+	}
+
+	oldProps, _ := old["properties"].(map[string]any)
+	newProps, _ := latest["properties"].(map[string]any)
+	keys := make([]string, 0, len(oldProps))
+	for key := range oldProps {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		newProp, exists := newProps[key]
+		if !exists {
+			// 请求方向允许额外字段时, 删除字段不影响旧调用方
+			if !input || latest["additionalProperties"] == false {
+				*changes = append(*changes, Change{Path: join(path, key), Reason: "field removed"})
+			}
+			continue
+		}
+		oldProp, _ := oldProps[key].(map[string]any)
+		newPropMap, _ := newProp.(map[string]any)
+		diff(join(path, key), oldProp, newPropMap, input, changes)
+	}
+
+	if oldItems, ok := old["items"].(map[string]any); ok {
+		newItems, _ := latest["items"].(map[string]any)
+		diff(path+"[]", oldItems, newItems, input, changes)
+	}
+	if oldAP, ok := old["additionalProperties"].(map[string]any); ok {
+		newAP, _ := latest["additionalProperties"].(map[string]any)
+		diff(path+"{}", oldAP, newAP, input, changes)
+	}
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// schemaTypes type可能为字符串或字符串列表
+func schemaTypes(schema map[string]any) []string {
+	var types []string
+	switch t := schema["type"].(type) {
+	case string:
+		types = []string{t}
+	default:
+		types = stringList(t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func stringList(v any) []string {
+	switch l := v.(type) {
+	case []string:
+		return l
+	case []any:
+		values := make([]string, 0, len(l))
+		for _, item := range l {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func stringSet(v any) map[string]bool {
+	set := make(map[string]bool)
+	for _, s := range stringList(v) {
+		set[s] = true
+	}
+	return set
+}
+
+// subset a是否为b的子集, integer视为number的子集
+func subset(a, b []string) bool {
+	set := make(map[string]bool, len(b))
+	for _, s := range b {
+		set[s] = true
+	}
+	for _, s := range a {
+		if set[s] || (s == "integer" && set["number"]) {
+			continue
+		}
+		return false
+	}
+	return true
+}
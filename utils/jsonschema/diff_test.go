@@ -0,0 +1,50 @@
+package jsonschema
+
+import (
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old := map[string]any{
+		"type":     "object",
+		"required": []any{"name"},
+		"properties": map[string]any{
+			"name": map[string]any{"type": "string"},
+			"age":  map[string]any{"type": "integer"},
+			"tags": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+		},
+	}
+	latest := map[string]any{
+		"type":                 "object",
+		"required":             []any{"name", "email"},
+		"additionalProperties": false,
+		"properties": map[string]any{
+			"name":  map[string]any{"type": []any{"string", "null"}},
+			"email": map[string]any{"type": "string"},
+			"tags":  map[string]any{"type": "array", "items": map[string]any{"type": "integer"}},
+		},
+	}
+	changes := map[string]string{}
+	for _, change := range Diff(old, latest, true) {
+		changes[change.Path] = change.Reason
+	}
+	// name类型扩大对请求方向兼容
+	if _, ok := changes["name"]; ok || len(changes) != 3 {
+		t.Fatalf("unexpected input changes %v", changes)
+	}
+	if changes["email"] != "new required field" || changes["age"] != "field removed" || changes["tags[]"] == "" {
+		t.Fatalf("unexpected input changes %v", changes)
+	}
+
+	changes = map[string]string{}
+	for _, change := range Diff(old, latest, false) {
+		changes[change.Path] = change.Reason
+	}
+	// 返回方向类型扩大不兼容
+	if changes["name"] == "" || changes["age"] != "field removed" {
+		t.Fatalf("unexpected output changes %v", changes)
+	}
+	if len(Diff(old, old, true)) != 0 || len(Diff(old, old, false)) != 0 {
+		t.Fatal("identical schemas should be compatible")
+	}
+}
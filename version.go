@@ -33,18 +33,32 @@ func (v Version) Version(patch ...bool) string {
 func (v Version) Compare(version Version, patch ...bool) int {
 	if v.Major > version.Major {
 		return 1
-	} else if v.Major < v.Major {
+	} else if v.Major < version.Major {
 		return -1
 	}
 	if v.Minor > version.Minor {
 		return 1
-	} else if v.Minor < v.Minor {
+	} else if v.Minor < version.Minor {
 		return -1
 	}
 	if len(patch) > 0 && patch[0] {
 		if v.Patch > version.Patch {
 			return 1
-		} else if v.Patch < v.Patch {
+		} else if v.Patch < version.Patch {
+			return -1
+		}
+	}
+	return 0
+}
+
+// compareParts 仅比较前parts段版本号
+func (v Version) compareParts(version Version, parts int) int {
+	a := []int{v.Major, v.Minor, v.Patch}
+	b := []int{version.Major, version.Minor, version.Patch}
+	for i := 0; i < parts && i < len(a); i++ {
+		if a[i] > b[i] {
+			return 1
+		} else if a[i] < b[i] {
 			return -1
 		}
 	}
@@ -0,0 +1,82 @@
+package micro
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestVersionCompare(t *testing.T) {
+	cases := []struct {
+		a, b   Version
+		patch  bool
+		expect int
+	}{
+		{Version{2, 3, 0}, Version{2, 3, 9}, false, 0},
+		{Version{2, 3, 0}, Version{2, 3, 9}, true, -1},
+		{Version{2, 4, 0}, Version{2, 3, 9}, true, 1},
+		{Version{1, 9, 9}, Version{2, 0, 0}, false, -1},
+		{Version{3, 0, 0}, Version{2, 9, 9}, false, 1},
+		{Version{2, 2, 0}, Version{2, 3, 0}, false, -1},
+	}
+	for _, c := range cases {
+		if r := c.a.Compare(c.b, c.patch); r != c.expect {
+			t.Errorf("%s compare %s expect %d got %d", c.a.Version(true), c.b.Version(true), c.expect, r)
+		}
+	}
+}
+
+func TestConstraint(t *testing.T) {
+	cases := map[string]map[Version]bool{
+		">=2.3 <3":      {{2, 3, 0}: true, {2, 9, 1}: true, {2, 2, 9}: false, {3, 0, 0}: false},
+		"~2.4":          {{2, 4, 0}: true, {2, 4, 7}: true, {2, 5, 0}: false},
+		"~2.4.1":        {{2, 4, 1}: true, {2, 4, 0}: false, {2, 5, 0}: false},
+		"^2.4.1":        {{2, 4, 1}: true, {2, 9, 0}: true, {2, 4, 0}: false, {3, 0, 0}: false},
+		">2.3":          {{2, 3, 9}: false, {2, 4, 0}: true},
+		"<=2.3":         {{2, 3, 9}: true, {2, 4, 0}: false},
+		"2.3.x":         {{2, 3, 5}: true, {2, 4, 0}: false},
+		"!=2.3":         {{2, 3, 5}: false, {2, 4, 0}: true},
+		"2.3 || >= 3.1": {{2, 3, 1}: true, {3, 0, 0}: false, {3, 2, 0}: true},
+		"*":             {{1, 0, 0}: true},
+	}
+	for expr, versions := range cases {
+		c, err := NewConstraint(expr)
+		if err != nil {
+			t.Fatalf("parse %q failed: %v", expr, err)
+		}
+		for v, expect := range versions {
+			if c.Check(v) != expect {
+				t.Errorf("%q check %s expect %v", expr, v.Version(true), expect)
+			}
+		}
+	}
+
+	for _, expr := range []string{"", ">=", "2.a", "1.2.3.4", ">=2.3 ||"} {
+		if _, err := NewConstraint(expr); err == nil {
+			t.Errorf("expression %q should fail", expr)
+		}
+	}
+
+	var target struct {
+		Constraint *Constraint `json:"constraint"`
+	}
+	if err := json.Unmarshal([]byte(`{"constraint": "~2.4"}`), &target); err != nil {
+		t.Fatal(err)
+	}
+	if !target.Constraint.Check(Version{2, 4, 3}) {
+		t.Errorf("unmarshal constraint failed")
+	}
+}
+
+func TestNodeCompatible(t *testing.T) {
+	node := &Node{Version: Version{2, 4, 0}, Min: &Version{2, 2, 0}, Max: &Version{2, 4, 0}}
+	if !node.Compatible(Version{2, 3, 0}) || node.Compatible(Version{2, 1, 0}) || node.Compatible(Version{2, 5, 0}) {
+		t.Errorf("min/max compatible check failed")
+	}
+	node.Compatibility, _ = NewConstraint(">=2.0 <2.3")
+	if !node.Compatible(Version{2, 1, 0}) || node.Compatible(Version{2, 3, 0}) {
+		t.Errorf("constraint compatible check failed")
+	}
+	if err := json.Unmarshal([]byte(`{"id": "a", "compatibility": ">=a.b"}`), new(Node)); err == nil {
+		t.Errorf("invalid node constraint decoded")
+	}
+}